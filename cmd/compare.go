@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nullxjx/llm_profiler/internal/report"
+	"github.com/nullxjx/llm_profiler/pkg/store/cos"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const cosScheme = "cos://"
+
+var thresholds report.Thresholds
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <baseline_dir> <candidate_dir> [candidate_dir...]",
+	Short: "对比多次压测结果，检测性能退化",
+	Long: "按并发度对齐多次压测的结果，以第一个目录为基线，计算吞吐量、延迟、首token时间、成功率的变化，" +
+		"出现超过阈值且统计显著的退化时以非零状态码退出。目录以 cos:// 开头时从 cos 下载",
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		defer func() {
+			if err != nil {
+				fmt.Printf("compare err: %v", err.Error())
+				os.Exit(1)
+			}
+		}()
+
+		var regressions int
+		if regressions, err = compareRuns(args); err == nil && regressions > 0 {
+			err = fmt.Errorf("regression gate failed, %d regressions found", regressions)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+	compareCmd.Flags().Float64Var(&thresholds.Throughput, "throughput_drop", 5, "吞吐量下降超过该百分比视为退化")
+	compareCmd.Flags().Float64Var(&thresholds.Latency, "latency_increase", 10, "延迟上升超过该百分比视为退化")
+	compareCmd.Flags().Float64Var(&thresholds.FirstToken, "first_token_increase", 10, "首token时间上升超过该百分比视为退化")
+	compareCmd.Flags().Float64Var(&thresholds.SuccessRate, "success_rate_drop", 1, "成功率下降超过该百分点视为退化")
+	compareCmd.Flags().Float64Var(&thresholds.Alpha, "alpha", 0.05, "显著性水平，有单条请求结果时只有p值小于该值的变化才视为退化")
+}
+
+// compareRuns 以第一个目录为基线依次对比，返回退化的指标数
+func compareRuns(dirs []string) (int, error) {
+	var runs []*report.Run
+	for _, dir := range dirs {
+		run, err := loadRun(dir)
+		if err != nil {
+			return 0, err
+		}
+		runs = append(runs, run)
+	}
+
+	regressions := 0
+	for _, candidate := range runs[1:] {
+		c := report.Compare(runs[0], candidate, &thresholds)
+		c.Print(os.Stdout)
+		fmt.Println()
+		regressions += len(c.Regressions())
+	}
+	return regressions, nil
+}
+
+// loadRun 读取一次运行的结果，结果全部读入内存后即可删除下载的临时目录
+func loadRun(dir string) (*report.Run, error) {
+	localDir, cleanup, err := fetchDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	run, err := report.Load(localDir, true)
+	if err != nil {
+		return nil, err
+	}
+	run.Dir = dir
+	return run, nil
+}
+
+// fetchDir 目录以 cos:// 开头时下载到本地临时目录，返回本地目录和删除临时目录的函数，下载失败时临时目录已被删除
+func fetchDir(dir string) (string, func(), error) {
+	if !strings.HasPrefix(dir, cosScheme) {
+		return dir, func() {}, nil
+	}
+	localDir, err := os.MkdirTemp("", "perf_compare_")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(localDir); err != nil {
+			log.Warnf("remove %s error: %v", localDir, err)
+		}
+	}
+	log.Debugf("download %s to %s", dir, localDir)
+	if err = cos.DownloadCosDir(strings.TrimPrefix(dir, cosScheme), localDir); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return localDir, cleanup, nil
+}
@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/exporter"
 	"github.com/nullxjx/llm_profiler/internal/perf/speed"
 	"github.com/nullxjx/llm_profiler/internal/perf/throughput"
 	"github.com/nullxjx/llm_profiler/internal/utils"
@@ -14,7 +16,12 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var configPath string
+var (
+	configPath string
+	resume     bool
+	tui        bool
+	overrides  []string
+)
 
 var customCmd = &cobra.Command{
 	Use:   "custom",
@@ -29,45 +36,101 @@ var customCmd = &cobra.Command{
 			}
 		}()
 
-		customTest()
+		err = customTest()
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(customCmd)
 	customCmd.Flags().StringVarP(&configPath, "config_path", "c", "config/config_local.yml", "配置文件路径")
+	customCmd.Flags().BoolVarP(&resume, "resume", "r", false, "从saveDir中的断点继续测试，要求配置未被修改")
+	customCmd.Flags().BoolVar(&tui, "tui", false, "在终端显示实时面板，等同于配置中的tui: true")
+	customCmd.Flags().StringArrayVar(&overrides, "set", nil, "覆盖配置项，格式为key=value，例如 --set maxTokens=256 --set model.name=llama")
 }
 
-func customTest() {
-	cfg, err := config.ReadConf(configPath)
+func customTest() error {
+	cfg, err := config.ReadConf(configPath, overrides...)
 	if err != nil {
-		fmt.Printf("read config error: %v\n", err)
-		return
+		return fmt.Errorf("read config error: %v", err)
+	}
+	if tui {
+		cfg.Tui = true
+	}
+	ctx, cancel := withInterrupt(context.Background())
+	defer cancel()
+	if cfg.MetricsAddr != "" {
+		exporter.Serve(cfg.MetricsAddr)
 	}
+	return runConfig(ctx, cfg, resume)
+}
 
-	// 判断saveDir是否为空，不为空直接退出
-	if !utils.IsDirEmpty(cfg.SaveDir) {
-		log.Errorf("local save dir: %s is not empty", cfg.SaveDir)
-		return
+// runCustom 使用给定配置进行一次吞吐量测试，resume 为 true 时从 saveDir 中的断点继续测试
+func runCustom(ctx context.Context, cfg *config.Config, resume bool) error {
+	var cp *throughput.Checkpoint
+	var err error
+	if resume {
+		// 断点需要在配置被修改（如设置MaxStreamSpeed）之前校验
+		if cp, err = throughput.LoadCheckpoint(cfg); err != nil {
+			return fmt.Errorf("resume from %s error: %v", cfg.SaveDir, err)
+		}
+	} else {
+		// 判断saveDir是否为空，不为空直接退出
+		if !utils.IsDirEmpty(cfg.SaveDir) {
+			return fmt.Errorf("local save dir: %s is not empty, use --resume to continue", cfg.SaveDir)
+		}
+		if cp, err = throughput.NewCheckpoint(cfg); err != nil {
+			return fmt.Errorf("create checkpoint error: %v", err)
+		}
+	}
+	setLogFile := logformat.SetLogFile
+	if cfg.Tui {
+		setLogFile = logformat.SetLogFileOnly
+	}
+	restoreLog, err := setLogFile(cfg.SaveDir + "/test.log")
+	if err != nil {
+		return err
 	}
-	if err := logformat.SetLogFile(cfg.SaveDir + "/test.log"); err != nil {
-		return
+	defer restoreLog()
+	if err = saveEffectiveConfig(cfg); err != nil {
+		return err
 	}
+
 	log.Infof("Begin performance testing on the model %v at %v:%v, backend: %v",
 		cfg.Model.Name, cfg.ServerIp, cfg.Port, cfg.Backend)
 	log.Infof("Concurrency from %vreqs/%vmin to %vreqs/%vmin, Increment: %v reqs, stream: %v",
 		cfg.StartConcurrency, cfg.Duration, cfg.EndConcurrency, cfg.Duration, cfg.Increment, cfg.Stream)
+	if resume {
+		log.Infof("Resume from concurrency %v with %v finished rounds", cp.NextConcurrency, len(cp.Rounds))
+		if cp.MaxStreamSpeed > 0 {
+			cfg.MaxStreamSpeed = cp.MaxStreamSpeed
+		}
+	}
 	if cfg.Stream && cfg.MaxStreamSpeed == 0 {
 		// 先测出只有一条请求的时的速度（每秒token数），可以使用多条输入数据测试几次取均值
 		log.Infof("Calculate max stream speed...")
-		s, err := speed.CalStreamSpeed(cfg)
+		s, err := speed.CalStreamSpeed(ctx, cfg)
+		if ctx.Err() != nil {
+			return fmt.Errorf("interrupted while calculating max stream speed")
+		}
 		if err != nil {
-			log.Errorf("calculate max speed error: %v", err)
-			return
+			return fmt.Errorf("calculate max speed error: %v", err)
 		}
 		log.Infof("🍭🍻🚀 Max stream speed: %.1f tokens/s, first_token: %.1f ms", s.TokensPerSecond, s.FirstTokenTime)
 		cfg.MaxStreamSpeed = s.TokensPerSecond
 	}
-	throughput.StartTest(cfg)
+	if _, _, err = throughput.StartTest(ctx, cfg, cp); err != nil {
+		return err
+	}
 	log.Infof("Done")
+	return nil
+}
+
+// saveEffectiveConfig 打印并保存合并了环境变量和命令行覆盖后的生效配置
+func saveEffectiveConfig(cfg *config.Config) error {
+	effective, err := config.Effective(cfg)
+	if err != nil {
+		return fmt.Errorf("marshal effective config error: %v", err)
+	}
+	log.Infof("Effective config:\n%s", effective)
+	return os.WriteFile(cfg.SaveDir+"/effective_config.yml", []byte(effective), 0644)
 }
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/nullxjx/llm_profiler/internal/dataset"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	buildOpts   dataset.BuildOptions
+	bucketStep  int
+	bucketLimit int
+)
+
+var datasetCmd = &cobra.Command{
+	Use:   "dataset",
+	Short: "数据集相关工具",
+	Long:  "数据集相关工具",
+}
+
+var datasetBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "使用本地tokenizer把语料按token数分组",
+	Long: "读取 ShareGPT、Alpaca 或 jsonl 格式的语料，使用本地的 huggingface tokenizer.json 统计每条 prompt 的 token 数，" +
+		"按最接近的分组保存为 input_tokens_N.json，可以用来构建与被测模型 tokenizer 一致的数据集",
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		defer func() {
+			if err != nil {
+				fmt.Printf("build dataset err: %v", err.Error())
+				os.Exit(1)
+			}
+		}()
+
+		err = buildDataset()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(datasetCmd)
+	datasetCmd.AddCommand(datasetBuildCmd)
+	flags := datasetBuildCmd.Flags()
+	flags.StringVarP(&buildOpts.Input, "input", "i", "", "语料文件路径")
+	flags.StringVarP(&buildOpts.Format, "format", "f", dataset.FormatShareGPT, "语料格式，支持sharegpt、alpaca、jsonl")
+	flags.StringVarP(&buildOpts.Tokenizer, "tokenizer", "t", "", "huggingface格式的tokenizer.json文件路径")
+	flags.StringVarP(&buildOpts.OutputDir, "output", "o", "data/custom", "分组文件的保存目录")
+	flags.IntSliceVar(&buildOpts.Buckets, "buckets", nil, "分组的token数，例如 128,512,2048，设置后忽略--bucket_step和--max_tokens")
+	flags.IntVar(&bucketStep, "bucket_step", 100, "分组的步长")
+	flags.IntVar(&bucketLimit, "max_tokens", 6000, "最大的分组")
+	flags.Float64Var(&buildOpts.Tolerance, "tolerance", 0.05, "token数与分组的最大相对差距")
+	flags.IntVar(&buildOpts.MaxCount, "max_count", 0, "每个分组最多保留的prompt数，0表示不限制")
+	_ = datasetBuildCmd.MarkFlagRequired("input")
+	_ = datasetBuildCmd.MarkFlagRequired("tokenizer")
+}
+
+func buildDataset() error {
+	if len(buildOpts.Buckets) == 0 {
+		buildOpts.Buckets = dataset.Buckets(bucketStep, bucketLimit)
+	}
+	counts, err := dataset.Build(&buildOpts)
+	if err != nil {
+		return err
+	}
+	buckets := make([]int, 0, len(counts))
+	for bucket := range counts {
+		buckets = append(buckets, bucket)
+	}
+	sort.Ints(buckets)
+	for _, bucket := range buckets {
+		log.Infof("input_tokens_%d: %d prompts", bucket, counts[bucket])
+	}
+	log.Infof("%v buckets saved to %v", len(buckets), buildOpts.OutputDir)
+	return nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/perf/throughput"
+	"github.com/nullxjx/llm_profiler/internal/utils"
+	"github.com/nullxjx/llm_profiler/internal/validate"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// matrixSummary 参数矩阵中一个组合的测试结果
+type matrixSummary struct {
+	Name                     string  `json:"name"`
+	InputTokens              int     `json:"input_tokens"`
+	MaxTokens                uint32  `json:"max_tokens"`
+	Temperature              float32 `json:"temperature"`
+	Stream                   bool    `json:"stream"`
+	Backend                  string  `json:"backend"`
+	MaxRequestPerSecond      float64 `json:"max_request_per_second"`
+	MaxInputTokensPerSecond  float64 `json:"max_input_tokens_per_second"`
+	MaxOutputTokensPerSecond float64 `json:"max_output_tokens_per_second"`
+	Error                    string  `json:"error,omitempty"`
+}
+
+// runConfig 根据配置进行测试，设置了参数矩阵时对每个参数组合分别测试
+func runConfig(ctx context.Context, cfg *config.Config, resume bool) error {
+	if err := validate.Config(cfg); err != nil {
+		return err
+	}
+	if cfg.Matrix.Empty() {
+		return runCustom(ctx, cfg, resume)
+	}
+	return runMatrix(ctx, cfg, resume)
+}
+
+// runMatrix 依次测试参数矩阵中的每个组合，最后汇总每个组合的最大吞吐量
+func runMatrix(ctx context.Context, cfg *config.Config, resume bool) error {
+	if !resume && !utils.IsDirEmpty(cfg.SaveDir) {
+		return fmt.Errorf("local save dir: %s is not empty, use --resume to continue", cfg.SaveDir)
+	}
+	cells := config.ExpandMatrix(cfg)
+	log.Infof("Parameter matrix has %d cells", len(cells))
+
+	var summaries []*matrixSummary
+	for i, cell := range cells {
+		if ctx.Err() != nil {
+			break
+		}
+		log.Infof("🧮 [%d/%d] testing %s", i+1, len(cells), cell.Name)
+		c := cell.Config
+		summary := &matrixSummary{
+			Name:        cell.Name,
+			InputTokens: c.InputTokens,
+			MaxTokens:   c.MaxTokens,
+			Temperature: c.Temperature,
+			Stream:      c.Stream,
+			Backend:     c.Backend,
+		}
+		// 断点恢复时，已经有断点的组合从断点继续，其他组合重新测试
+		_, err := throughput.ReadCheckpoint(c.SaveDir)
+		cellResume := resume && err == nil
+		if err = runCustom(ctx, c, cellResume); err != nil {
+			log.Errorf("test %s error: %v", cell.Name, err)
+			summary.Error = err.Error()
+		} else {
+			summary.MaxRequestPerSecond, summary.MaxInputTokensPerSecond, summary.MaxOutputTokensPerSecond =
+				throughput.GetMaxThroughput()
+		}
+		summaries = append(summaries, summary)
+	}
+
+	utils.Save2Json(summaries, filepath.Join(cfg.SaveDir, "matrix_summary.json"))
+	printMatrixSummary(summaries)
+	return nil
+}
+
+func printMatrixSummary(summaries []*matrixSummary) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "cell\tinput\toutput\ttemp\tstream\tbackend\treq/s\tinput tokens/s\toutput tokens/s\t")
+	for _, s := range summaries {
+		if s.Error != "" {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%g\t%v\t%s\terror: %s\t\t\t\n",
+				s.Name, s.InputTokens, s.MaxTokens, s.Temperature, s.Stream, s.Backend, s.Error)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%g\t%v\t%s\t%.2f\t%.1f\t%.1f\t\n",
+			s.Name, s.InputTokens, s.MaxTokens, s.Temperature, s.Stream, s.Backend,
+			s.MaxRequestPerSecond, s.MaxInputTokensPerSecond, s.MaxOutputTokensPerSecond)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/probe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	probeConfigPath string
+	probeUrl        string
+	probeModel      string
+	probeMaxTokens  uint32
+	probeTimeout    int
+	probeWrite      bool
+)
+
+var probeCmd = &cobra.Command{
+	Use:   "probe",
+	Short: "探测推理服务的后端类型、模型和上下文长度",
+	Long: "依次请求 /info、/v2/health/ready、/v1/models 等接口识别推理后端，输出服务中的模型、上下文长度和引擎版本，" +
+		"并以压测时的请求方式分别发送一条非流式和流式测试请求，--write 会把识别到的 model.name 和 backend 写入配置文件",
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		defer func() {
+			if err != nil {
+				fmt.Printf("probe err: %v\n", err.Error())
+				os.Exit(1)
+			}
+		}()
+
+		err = probeServer()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(probeCmd)
+	probeCmd.Flags().StringVarP(&probeConfigPath, "config_path", "c", "", "配置文件路径，设置后探测配置中的服务地址")
+	probeCmd.Flags().StringArrayVar(&overrides, "set", nil, "覆盖配置项，格式为key=value，需要同时指定配置文件")
+	probeCmd.Flags().StringVarP(&probeUrl, "url", "u", "", "服务地址，例如 http://127.0.0.1:8000，优先于配置文件中的地址")
+	probeCmd.Flags().StringVarP(&probeModel, "model", "m", "", "测试请求使用的模型，默认使用配置中的模型，服务中没有该模型时使用服务中的第一个模型")
+	probeCmd.Flags().Uint32Var(&probeMaxTokens, "max_tokens", 16, "测试请求生成的最大token数")
+	probeCmd.Flags().IntVar(&probeTimeout, "timeout", 30000, "每个请求的超时时间，单位为毫秒")
+	probeCmd.Flags().BoolVar(&probeWrite, "write", false, "把识别到的 model.name 和 backend 写入配置文件")
+}
+
+func probeServer() error {
+	cfg := &config.Config{Temperature: 1}
+	if probeConfigPath != "" {
+		var err error
+		if cfg, err = config.ReadConf(probeConfigPath, overrides...); err != nil {
+			return fmt.Errorf("read config error: %v", err)
+		}
+	} else if len(overrides) > 0 || probeWrite {
+		return errors.New("--set and --write require --config_path")
+	}
+	url := probeUrl
+	if url == "" {
+		if probeConfigPath == "" {
+			return errors.New("either --url or --config_path is required")
+		}
+		url = config.GetUrl(cfg)
+	}
+	model := probeModel
+	if model == "" {
+		model = cfg.Model.Name
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(probeTimeout)*time.Millisecond)
+	info, err := probe.Detect(ctx, url, model)
+	cancel()
+	if err != nil {
+		return err
+	}
+	printProbeInfo(info)
+	if model == "" || !info.Has(model) {
+		if model != "" {
+			fmt.Printf("model %s is not served, available: %v\n", model, modelNames(info))
+		}
+		model = info.DefaultModel()
+	}
+
+	c := *cfg
+	c.Backend, c.Model.Name, c.Model.Mix = info.Backend, model, nil
+	c.Domain, c.Endpoints = info.Url, nil
+	c.MaxTokens, c.RequestTimeout = probeMaxTokens, probeTimeout
+	fmt.Printf("test requests with model %s, max tokens %d:\n", model, probeMaxTokens)
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Duration(probeTimeout)*time.Millisecond)
+	defer cancel()
+	failed := 0
+	for _, r := range probe.TestModes(ctx, &c) {
+		mode := "non-stream"
+		if r.Stream {
+			mode = "stream"
+		}
+		if !r.Ok {
+			failed++
+			fmt.Printf("  %-10s failed, see the error above\n", mode)
+			continue
+		}
+		fmt.Printf("  %-10s ok, time: %d ms, first token: %.1f ms, output tokens: %d, output: %q\n",
+			mode, r.TimeSpent, r.FirstTokenTime, r.OutputTokens, r.Output)
+	}
+
+	if probeWrite {
+		values := map[string]string{"model.name": model, "backend": info.Backend}
+		if err = config.Fill(probeConfigPath, values); err != nil {
+			return fmt.Errorf("write config error: %v", err)
+		}
+		fmt.Printf("model.name: %s, backend: %s written to %s\n", model, info.Backend, probeConfigPath)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d test requests failed", failed)
+	}
+	return nil
+}
+
+func printProbeInfo(info *probe.Info) {
+	fmt.Printf("url: %s\nbackend: %s\n", info.Url, info.Backend)
+	if info.Version != "" {
+		fmt.Printf("version: %s\n", info.Version)
+	}
+	fmt.Println("models:")
+	for _, m := range info.Models {
+		line := "  " + m.Name
+		if m.Parent != "" {
+			line += fmt.Sprintf(" (adapter of %s)", m.Parent)
+		}
+		if m.MaxContext > 0 {
+			line += fmt.Sprintf(", max context: %d", m.MaxContext)
+		}
+		keys := make([]string, 0, len(m.Limits))
+		for key := range m.Limits {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var limits []string
+		for _, key := range keys {
+			limits = append(limits, fmt.Sprintf("%s: %d", key, m.Limits[key]))
+		}
+		if len(limits) > 0 {
+			line += ", " + strings.Join(limits, ", ")
+		}
+		fmt.Println(line)
+	}
+}
+
+func modelNames(info *probe.Info) []string {
+	names := make([]string, 0, len(info.Models))
+	for _, m := range info.Models {
+		names = append(names, m.Name)
+	}
+	return names
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nullxjx/llm_profiler/internal/report"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportDir    string
+	reportOutput string
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "把压测结果目录生成为html报告",
+	Long:  "把压测结果目录生成为一个不依赖外部资源的html报告，包含吞吐量、延迟、首token时间等图表和配置",
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		defer func() {
+			if err != nil {
+				fmt.Printf("generate report err: %v", err.Error())
+				os.Exit(1)
+			}
+		}()
+
+		err = generateReport()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.Flags().StringVarP(&reportDir, "dir", "d", "", "压测结果目录，即配置中的saveDir")
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "", "html报告保存路径，默认为<dir>/report.html")
+	_ = reportCmd.MarkFlagRequired("dir")
+}
+
+func generateReport() error {
+	run, err := report.Load(reportDir, true)
+	if err != nil {
+		return err
+	}
+	output := reportOutput
+	if output == "" {
+		output = filepath.Join(reportDir, "report.html")
+	}
+	if err = report.WriteHTML(run, output); err != nil {
+		return err
+	}
+	log.Infof("Report of %v rounds saved to %v", len(run.Rounds), output)
+	return nil
+}
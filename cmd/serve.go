@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/server"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr     string
+	serveDataDir  string
+	serveInputDir string
+	queueSize     int
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "以http服务的方式运行，通过接口提交和管理压测任务",
+	Long:  "以http服务的方式运行，通过REST接口提交压测任务、查询进度、取消任务和下载结果，任务依次执行，同一时间只有一个任务在压测",
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		defer func() {
+			if err != nil {
+				fmt.Printf("serve err: %v", err.Error())
+				os.Exit(1)
+			}
+		}()
+
+		err = serve()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVarP(&serveAddr, "addr", "a", ":8088", "服务监听地址")
+	serveCmd.Flags().StringVarP(&serveDataDir, "data_dir", "d", "jobs", "任务结果保存目录，每个任务保存在<data_dir>/<job id>下")
+	serveCmd.Flags().StringVarP(&serveInputDir, "input_dir", "i", "data",
+		"任务可以读取的数据集、tokenizer、histogram等输入文件所在目录，配置中的输入文件不在该目录下时拒绝任务，设置为空时不限制")
+	serveCmd.Flags().IntVarP(&queueSize, "queue_size", "q", 10, "最多排队的任务数")
+}
+
+func serve() error {
+	ctx, cancel := withInterrupt(context.Background())
+	defer cancel()
+
+	manager := server.NewManager(func(ctx context.Context, cfg *config.Config) error {
+		return runConfig(ctx, cfg, false)
+	}, serveDataDir, serveInputDir, queueSize)
+	manager.Start(ctx)
+
+	srv := &http.Server{Addr: serveAddr, Handler: server.NewHandler(manager)}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer shutdownCancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	log.Infof("Serving profiler jobs at %v", serveAddr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Infof("Waiting for the running job to save results...")
+	<-manager.Done()
+	return nil
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// withInterrupt 返回一个收到 SIGINT/SIGTERM 时被取消的 context，再次收到信号时直接退出进程
+func withInterrupt(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-sigs:
+			log.Warnf("Received %v, stopping and saving partial results, send again to force exit", sig)
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(sigs)
+			return
+		}
+		sig := <-sigs
+		log.Errorf("Received %v again, force exit", sig)
+		os.Exit(1)
+	}()
+	return ctx, cancel
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/nullxjx/llm_profiler/config"
 	"github.com/nullxjx/llm_profiler/internal/perf/speed"
 	logformat "github.com/nullxjx/llm_profiler/pkg/log"
 
@@ -24,7 +25,7 @@ var speedCmd = &cobra.Command{
 			}
 		}()
 
-		speedTest()
+		err = speedTest(cmd)
 	},
 }
 
@@ -34,16 +35,65 @@ func init() {
 	speedCmd.Flags().IntVarP(&port, "port", "p", 8000, "模型端口")
 	speedCmd.Flags().StringVarP(&model, "model", "m", "codellama", "模型名字")
 	speedCmd.Flags().StringVarP(&backend, "backend", "b", "vllm", "部署模型用的框架，当前支持vllm、tgi、trt")
-	speedCmd.Flags().IntVarP(&prompt, "prompt", "l", 0, "prompt长度，0表示采用默认较短的prompt")
+	speedCmd.Flags().IntVarP(&prompt, "prompt", "l", 0, "prompt的token数，0表示采用默认较短的prompt，数据集中没有该长度时随机生成")
+	speedCmd.Flags().StringVar(&tokenizerPath, "tokenizer", "", "tokenizer.json 路径，设置后随机生成加上默认前缀恰好为 prompt 长度个token的 prompt")
 	speedCmd.Flags().Float32VarP(&temperature, "temperature", "t", 1, "温度，默认为1")
+	speedCmd.Flags().StringVarP(&speedConfigPath, "config_path", "c", "", "配置文件路径，设置后从配置中读取未在命令行指定的参数")
+	speedCmd.Flags().StringArrayVar(&overrides, "set", nil, "覆盖配置项，格式为key=value，需要同时指定配置文件")
 }
 
-func speedTest() {
-	if err := logformat.SetLogFile(user + "/test.log"); err != nil {
-		log.Errorf("set log file failed: %v", err)
-		return
+var (
+	speedConfigPath string
+	tokenizerPath   string
+)
+
+func speedTest(cmd *cobra.Command) error {
+	if speedConfigPath != "" {
+		if err := applySpeedConfig(cmd); err != nil {
+			return err
+		}
+	} else if len(overrides) > 0 {
+		return fmt.Errorf("--set requires --config_path")
 	}
-	speed.SpeedTest(ip, model, backend, port, prompt, temperature)
+	if _, err := logformat.SetLogFile(user + "/test.log"); err != nil {
+		return fmt.Errorf("set log file failed: %v", err)
+	}
+	speed.SpeedTest(ip, model, backend, port, prompt, temperature, tokenizerPath)
 
 	log.Infof("Done")
+	return nil
+}
+
+// applySpeedConfig 使用配置文件中的值填充未在命令行中指定的参数，命令行参数优先
+func applySpeedConfig(cmd *cobra.Command) error {
+	cfg, err := config.ReadConf(speedConfigPath, overrides...)
+	if err != nil {
+		return fmt.Errorf("read config error: %v", err)
+	}
+	flags := cmd.Flags()
+	if !flags.Changed("ip") {
+		ip = cfg.ServerIp
+	}
+	if !flags.Changed("port") {
+		port = cfg.Port
+	}
+	if !flags.Changed("model") {
+		model = cfg.Model.Name
+	}
+	if !flags.Changed("backend") {
+		backend = cfg.Backend
+	}
+	if !flags.Changed("prompt") {
+		prompt = cfg.InputTokens
+	}
+	if !flags.Changed("temperature") {
+		temperature = cfg.Temperature
+	}
+	if !flags.Changed("tokenizer") {
+		tokenizerPath = cfg.Prompt.Tokenizer
+	}
+	if user == "" {
+		user = cfg.SaveDir
+	}
+	return nil
 }
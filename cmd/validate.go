@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/validate"
+
+	"github.com/spf13/cobra"
+)
+
+var printEffective bool
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "校验配置文件",
+	Long:  "校验配置文件中的取值范围、各推理后端的必填项、数据集文件以及cos和webhook所需的环境变量，一次列出所有问题",
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		defer func() {
+			if err != nil {
+				fmt.Printf("validate config err: %v\n", err.Error())
+				os.Exit(1)
+			}
+		}()
+
+		err = validateConfig()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+	validateCmd.Flags().StringVarP(&configPath, "config_path", "c", "config/config_local.yml", "配置文件路径")
+	validateCmd.Flags().StringArrayVar(&overrides, "set", nil, "覆盖配置项，格式为key=value")
+	validateCmd.Flags().BoolVar(&printEffective, "print", false, "打印合并了环境变量和命令行覆盖后的生效配置")
+}
+
+func validateConfig() error {
+	cfg, err := config.ReadConf(configPath, overrides...)
+	if err != nil {
+		return fmt.Errorf("read config error: %v", err)
+	}
+	if printEffective {
+		effective, err := config.Effective(cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Println(effective)
+	}
+	if err = validate.Config(cfg); err != nil {
+		return err
+	}
+	fmt.Printf("%s is valid\n", configPath)
+	return nil
+}
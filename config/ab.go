@@ -0,0 +1,69 @@
+package config
+
+// A/B 对比测试中两个服务的默认名称
+const (
+	defaultBaselineName  = "a"
+	defaultCandidateName = "b"
+)
+
+// ABConfig A/B 对比测试，每条请求在同一时刻同时发送到配置中的服务（基线）和 candidate，按请求配对比较
+type ABConfig struct {
+	Name      string       `yaml:"name"`      // 基线服务的名称，默认 a
+	Candidate TargetConfig `yaml:"candidate"` // 对比的服务，没有设置的字段与基线相同
+}
+
+// TargetConfig A/B 对比测试中的对比服务
+type TargetConfig struct {
+	Name        string      `yaml:"name"`        // 名称，默认 b
+	Backend     string      `yaml:"backend"`     // 推理后端类型
+	Model       ModelConfig `yaml:"model"`       // 模型配置
+	ServerIp    string      `yaml:"serverIp"`    // 模型服务ip
+	Port        int         `yaml:"port"`        // 模型服务端口
+	Domain      string      `yaml:"domain"`      // 模型服务域名
+	Endpoints   []Endpoint  `yaml:"endpoints"`   // 模型服务的多个副本地址
+	LoadBalance string      `yaml:"loadBalance"` // 多个副本地址之间的负载均衡策略
+}
+
+// Enabled 是否开启 A/B 对比测试
+func (a *ABConfig) Enabled() bool {
+	c := &a.Candidate
+	return c.Backend != "" || c.Model.Name != "" || c.hasAddress()
+}
+
+// GetName 返回基线服务的名称
+func (a *ABConfig) GetName() string {
+	if a.Name == "" {
+		return defaultBaselineName
+	}
+	return a.Name
+}
+
+// GetName 返回对比服务的名称
+func (t *TargetConfig) GetName() string {
+	if t.Name == "" {
+		return defaultCandidateName
+	}
+	return t.Name
+}
+
+func (t *TargetConfig) hasAddress() bool {
+	return t.Domain != "" || t.ServerIp != "" || t.Port != 0 || len(t.Endpoints) > 0
+}
+
+// Candidate 返回对比服务的完整配置，没有设置的字段使用基线的值，设置了任一地址字段时整体替换基线的地址
+func (c *Config) Candidate() *Config {
+	t := &c.AB.Candidate
+	cand := *c
+	cand.AB = ABConfig{}
+	if t.Backend != "" {
+		cand.Backend = t.Backend
+	}
+	if t.Model.Name != "" {
+		cand.Model = t.Model
+	}
+	if t.hasAddress() {
+		cand.Domain, cand.ServerIp, cand.Port = t.Domain, t.ServerIp, t.Port
+		cand.Endpoints, cand.LoadBalance = t.Endpoints, t.LoadBalance
+	}
+	return &cand
+}
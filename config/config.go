@@ -1,8 +1,13 @@
 package config
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -12,67 +17,147 @@ const (
 	defaultSchema = "http"
 )
 
+// 结果文件中 prompt 和输出文本的保存方式
+const (
+	ResultTextFull = "full" // 保存原文
+	ResultTextHash = "hash" // 只保存 sha256
+	ResultTextNone = "none" // 不保存
+)
+
 type ModelConfig struct {
-	Name    string `yaml:"name"`
-	Version string `yaml:"version"`
+	Name    string          `yaml:"name"`
+	Version string          `yaml:"version"`
+	Mix     []WeightedModel `yaml:"mix"` // 多个模型或 LoRA adapter 按权重混合，每条请求选择其中一个，与 name 相同的名称表示基础模型
+}
+
+// WeightedModel 混合多个模型或 LoRA adapter 时的一个模型
+type WeightedModel struct {
+	Name   string  `yaml:"name"`   // 模型名称，vllm 中为 LoRA adapter 的名称，trt 中为模型仓库中的模型名称，tgi 中为 adapter_id
+	Weight float64 `yaml:"weight"` // 权重
 }
 
 // Config 服务配置
 type Config struct {
-	Model            ModelConfig `yaml:"model"`            // 模型配置
-	ServerIp         string      `yaml:"serverIp"`         // 模型服务ip
-	Port             int         `yaml:"port"`             // 模型服务端口
-	Domain           string      `yaml:"domain"`           // 模型服务域名
-	RequestTimeout   int         `yaml:"requestTimeout"`   // 单位为毫秒
-	Backend          string      `yaml:"backend"`          // 推理后端类型，例如 vllm、trt、tgi
-	StopWords        []string    `yaml:"stopWords"`        // stop words
-	MaxTokens        uint32      `yaml:"maxTokens"`        // 生成token的最大数量
-	Temperature      float32     `yaml:"temperature"`      // 模型温度
-	Stream           bool        `yaml:"stream"`           // 是否流式
-	InputTokens      int         `yaml:"inputTokens"`      // 输入token数量
-	StartConcurrency int         `yaml:"startConcurrency"` // 开始并发度，并发度指的是给定时间内发送的请求数目
-	EndConcurrency   int         `yaml:"endConcurrency"`   // 结束并发度
-	Increment        int         `yaml:"increment"`        // 并发度每一轮跟上一轮的增量
-	Duration         int         `yaml:"duration"`         // 每一轮请求持续时间，单位是分钟
-	TimeThresholds   []int64     `yaml:"timeThresholds"`   // 请求时间阈值
-	StreamThresholds int         `yaml:"streamThresholds"` // 流式模式下，当客户端流式速度低于最大流式速度的百分比时，停止发送请求
-	MaxStreamSpeed   float64     `yaml:"maxStreamSpeed"`   // 最大流式速度，在流式场景才有效，如果没有设置，则会先测试最大流式速度
-	SaveDir          string      `yaml:"saveDir"`          // 压测结果保存路径
-	SendMsg          bool        `yaml:"sendMsg"`          // 是否发送企微webhook消息
-	User             string      `yaml:"user"`             // 企微群中的用户
-	Save2Cos         bool        `json:"save2Cos"`         // 是否保存结果到cos
+	Model            ModelConfig        `yaml:"model"`            // 模型配置
+	ServerIp         string             `yaml:"serverIp"`         // 模型服务ip
+	Port             int                `yaml:"port"`             // 模型服务端口
+	Domain           string             `yaml:"domain"`           // 模型服务域名
+	Endpoints        []Endpoint         `yaml:"endpoints"`        // 模型服务的多个副本地址，设置后 domain、serverIp 和 port 失效
+	LoadBalance      string             `yaml:"loadBalance"`      // 多个副本地址之间的负载均衡策略：round_robin（默认）、least_inflight、random
+	RequestTimeout   int                `yaml:"requestTimeout"`   // 单位为毫秒
+	Backend          string             `yaml:"backend"`          // 推理后端类型，例如 vllm、trt、tgi
+	StopWords        []string           `yaml:"stopWords"`        // stop words
+	MaxTokens        uint32             `yaml:"maxTokens"`        // 生成token的最大数量
+	Temperature      float32            `yaml:"temperature"`      // 模型温度
+	Sampling         SamplingConfig     `yaml:"sampling"`         // 其他采样参数，不设置时使用推理服务的默认值
+	Stream           bool               `yaml:"stream"`           // 是否流式
+	InputTokens      int                `yaml:"inputTokens"`      // 输入token数量
+	Prompt           PromptConfig       `yaml:"prompt"`           // prompt 来源，默认使用按输入token数分组的 ShareGPT 数据集
+	Workload         WorkloadConfig     `yaml:"workload"`         // 混合长度的负载，设置后每条请求的输入和输出长度按分布采样
+	Session          SessionConfig      `yaml:"session"`          // 多轮对话负载，设置后每次发送开始一个多轮对话
+	Output           OutputConfig       `yaml:"output"`           // 目标输出长度和是否忽略 eos，默认所有请求忽略 eos 生成 maxTokens 个token
+	SharedPrefix     SharedPrefixConfig `yaml:"sharedPrefix"`     // 共享前缀的负载，设置后每条请求的 prompt 前面添加同一段前缀
+	AB               ABConfig           `yaml:"ab"`               // A/B 对比测试，设置后每条请求同时发送到两个服务
+	StartConcurrency int                `yaml:"startConcurrency"` // 开始并发度，并发度指的是给定时间内发送的请求数目
+	EndConcurrency   int                `yaml:"endConcurrency"`   // 结束并发度
+	Increment        int                `yaml:"increment"`        // 并发度每一轮跟上一轮的增量
+	Duration         int                `yaml:"duration"`         // 每一轮请求持续时间，单位是分钟
+	TimeThresholds   []int64            `yaml:"timeThresholds"`   // 请求时间阈值
+	StreamThresholds int                `yaml:"streamThresholds"` // 流式模式下，当客户端流式速度低于最大流式速度的百分比时，停止发送请求
+	MaxStreamSpeed   float64            `yaml:"maxStreamSpeed"`   // 最大流式速度，在流式场景才有效，如果没有设置，则会先测试最大流式速度
+	SaveDir          string             `yaml:"saveDir"`          // 压测结果保存路径
+	SendMsg          bool               `yaml:"sendMsg"`          // 是否发送企微webhook消息
+	User             string             `yaml:"user"`             // 企微群中的用户
+	Save2Cos         bool               `yaml:"save2Cos"`         // 是否保存结果到cos
+	ResultText       string             `yaml:"resultText"`       // 结果文件中prompt和输出的保存方式：full（默认）、hash、none
+	MetricsAddr      string             `yaml:"metricsAddr"`      // prometheus指标监听地址，例如 :8088，为空时不暴露指标
+	Tui              bool               `yaml:"tui"`              // 是否在终端显示实时面板，开启后日志只写入文件
+	Matrix           MatrixConfig       `yaml:"matrix"`           // 参数矩阵，设置后对其中所有参数组合分别进行测试
 }
 
-// ReadConf 读取配置
-func ReadConf(configPath string) (*Config, error) {
+// ReadConf 读取配置，配置文件中的值会被带 EnvPrefix 前缀的环境变量覆盖，再被 overrides 中 key=value 形式的值覆盖
+func ReadConf(configPath string, overrides ...string) (*Config, error) {
 	// 如果环境变量中存在值，则使用环境变量的值更新
 	if cp := os.Getenv(EnvConfigPath); cp != "" {
 		configPath = cp
 	}
-	//viper.AutomaticEnv()
-	viper.SetConfigType("yaml")
-	viper.SetConfigFile(configPath)
-	//viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	err := viper.ReadInConfig()
-	if err != nil {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	v.SetConfigFile(configPath)
+	if err := bindEnv(v); err != nil {
+		return nil, err
+	}
+	if err := v.ReadInConfig(); err != nil {
 		return nil, err
 	}
+	if err := applyOverrides(v, overrides); err != nil {
+		return nil, err
+	}
+	return unmarshal(v)
+}
+
+// ParseConf 从 yaml 或 json 格式的内容中解析配置
+func ParseConf(data []byte) (*Config, error) {
+	v := viper.New()
+	v.SetConfigType("yaml") // json 是 yaml 的子集，可以直接按 yaml 解析
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return unmarshal(v)
+}
+
+// unmarshal 把 viper 中读取到的内容解析为配置，并设置默认值
+func unmarshal(v *viper.Viper) (*Config, error) {
 	config := &Config{}
-	err = viper.Unmarshal(&config)
-	if err != nil {
+	if err := v.Unmarshal(&config); err != nil {
 		return nil, err
 	}
 
 	if config.Temperature == 0. { // 温度默认设置为1
 		config.Temperature = 1
 	}
+	if config.Sampling.Temperature != nil {
+		config.Temperature = *config.Sampling.Temperature
+	}
+	if config.ResultText == "" {
+		config.ResultText = ResultTextFull
+	}
 	return config, nil
 }
 
-// GetUrl 获取服务的URL
+// GetUrl 获取服务的URL，配置了多个副本地址时返回第一个
 func GetUrl(cfg *Config) string {
+	if len(cfg.Endpoints) > 0 {
+		return strings.TrimSuffix(cfg.Endpoints[0].Url, "/")
+	}
 	if cfg.Domain != "" {
 		return cfg.Domain
 	}
 	return fmt.Sprintf("%s://%s:%d", defaultSchema, cfg.ServerIp, cfg.Port)
 }
+
+// Clone 返回配置的深拷贝，运行时会修改配置（如 MaxStreamSpeed），需要与其他协程读取的配置分开
+func Clone(cfg *Config) (*Config, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Hash 计算配置的哈希值，用于判断两次运行的配置是否一致，只影响展示的字段不参与计算
+func Hash(cfg *Config) (string, error) {
+	c := *cfg
+	c.Tui = false
+	c.MetricsAddr = ""
+	data, err := json.Marshal(&c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
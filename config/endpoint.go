@@ -0,0 +1,30 @@
+package config
+
+// 多个推理服务地址之间的负载均衡策略
+const (
+	BalanceRoundRobin    = "round_robin"    // 按权重平滑轮询，默认值
+	BalanceLeastInflight = "least_inflight" // 选择进行中请求数与权重之比最小的地址
+	BalanceRandom        = "random"         // 按权重随机选择
+)
+
+// Endpoint 推理服务的一个副本地址
+type Endpoint struct {
+	Url    string `yaml:"url"`    // 完整地址，例如 http://10.0.0.1:8000
+	Weight int    `yaml:"weight"` // 权重，默认为 1
+}
+
+// GetWeight 返回地址的权重，默认为 1
+func (e *Endpoint) GetWeight() int {
+	if e.Weight <= 0 {
+		return 1
+	}
+	return e.Weight
+}
+
+// GetLoadBalance 返回负载均衡策略，默认为按权重轮询
+func (c *Config) GetLoadBalance() string {
+	if c.LoadBalance == "" {
+		return BalanceRoundRobin
+	}
+	return c.LoadBalance
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// MatrixConfig 参数矩阵，每个字段列出要测试的取值，会对所有取值的笛卡尔积分别进行一次吞吐量测试，
+// 没有设置的字段使用配置中的值
+type MatrixConfig struct {
+	InputTokens []int     `yaml:"inputTokens"` // 输入token数量
+	MaxTokens   []uint32  `yaml:"maxTokens"`   // 生成token的最大数量
+	Temperature []float32 `yaml:"temperature"` // 模型温度
+	Stream      []bool    `yaml:"stream"`      // 是否流式
+	Backends    []string  `yaml:"backends"`    // 推理后端类型
+}
+
+// MatrixCell 参数矩阵中的一个组合
+type MatrixCell struct {
+	Name   string  // 组合名称，同时也是结果保存的子目录名
+	Config *Config // 该组合对应的完整配置
+}
+
+// Empty 是否没有设置参数矩阵
+func (m *MatrixConfig) Empty() bool {
+	return len(m.InputTokens) == 0 && len(m.MaxTokens) == 0 && len(m.Temperature) == 0 &&
+		len(m.Stream) == 0 && len(m.Backends) == 0
+}
+
+// ExpandMatrix 展开参数矩阵，每个组合的结果保存在 saveDir/<组合名称> 目录下
+func ExpandMatrix(cfg *Config) []*MatrixCell {
+	base := *cfg
+	base.Matrix = MatrixConfig{}
+	cells := []*MatrixCell{{Config: &base}}
+	m := &cfg.Matrix
+	cells = expand(cells, len(m.InputTokens), func(c *Config, i int) string {
+		c.InputTokens = m.InputTokens[i]
+		return fmt.Sprintf("input_%d", c.InputTokens)
+	})
+	cells = expand(cells, len(m.MaxTokens), func(c *Config, i int) string {
+		c.MaxTokens = m.MaxTokens[i]
+		return fmt.Sprintf("output_%d", c.MaxTokens)
+	})
+	cells = expand(cells, len(m.Temperature), func(c *Config, i int) string {
+		c.Temperature = m.Temperature[i]
+		return fmt.Sprintf("temp_%g", c.Temperature)
+	})
+	cells = expand(cells, len(m.Stream), func(c *Config, i int) string {
+		c.Stream = m.Stream[i]
+		if c.Stream {
+			return "stream"
+		}
+		return "nonstream"
+	})
+	cells = expand(cells, len(m.Backends), func(c *Config, i int) string {
+		c.Backend = m.Backends[i]
+		return c.Backend
+	})
+	for _, cell := range cells {
+		cell.Name = strings.TrimPrefix(cell.Name, "_")
+		cell.Config.SaveDir = filepath.Join(cfg.SaveDir, cell.Name)
+	}
+	return cells
+}
+
+// expand 把每个组合按某个字段的 n 个取值展开，apply 设置第 i 个取值并返回名称片段
+func expand(cells []*MatrixCell, n int, apply func(c *Config, i int) string) []*MatrixCell {
+	if n == 0 {
+		return cells
+	}
+	res := make([]*MatrixCell, 0, len(cells)*n)
+	for _, cell := range cells {
+		for i := 0; i < n; i++ {
+			c := *cell.Config
+			res = append(res, &MatrixCell{
+				Name:   cell.Name + "_" + apply(&c, i),
+				Config: &c,
+			})
+		}
+	}
+	return res
+}
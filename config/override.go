@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 覆盖配置的环境变量前缀，例如 LLM_PROFILER_MAXTOKENS=256、LLM_PROFILER_MODEL_NAME=llama
+const EnvPrefix = "LLM_PROFILER"
+
+// Keys 返回所有可覆盖的配置项，嵌套的配置项用 . 连接，例如 model.name，
+// 元素为结构体的列表（如 endpoints、model.mix）无法用 key=value 表示，只能在配置文件中设置
+func Keys() []string {
+	return keys(reflect.TypeOf(Config{}), "")
+}
+
+func keys(t reflect.Type, prefix string) []string {
+	var result []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			result = append(result, keys(field.Type, prefix+name+".")...)
+			continue
+		}
+		if field.Type.Kind() == reflect.Slice && isStruct(field.Type.Elem()) {
+			continue
+		}
+		result = append(result, prefix+name)
+	}
+	return result
+}
+
+func isStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// bindEnv 使所有配置项都可以被带 EnvPrefix 前缀的环境变量覆盖
+func bindEnv(v *viper.Viper) error {
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	// AutomaticEnv 只对 viper 已知的配置项生效，配置文件中没有的配置项需要显式绑定
+	for _, key := range Keys() {
+		if err := v.BindEnv(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyOverrides 使用 key=value 形式的参数覆盖配置，优先级高于环境变量和配置文件
+// 列表类型的配置项用逗号分隔，例如 timeThresholds=500,1000
+func applyOverrides(v *viper.Viper, overrides []string) error {
+	known := make(map[string]bool)
+	for _, key := range Keys() {
+		known[strings.ToLower(key)] = true
+	}
+	for _, o := range overrides {
+		key, value, ok := strings.Cut(o, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("invalid override %q, expected key=value", o)
+		}
+		if !known[strings.ToLower(key)] {
+			return fmt.Errorf("unknown config key %q in override %q", key, o)
+		}
+		v.Set(key, value)
+	}
+	return nil
+}
+
+// Effective 返回 yaml 格式的生效配置，即合并了配置文件、环境变量和命令行覆盖后的结果
+func Effective(cfg *Config) (string, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// prompt 的来源
+const (
+	PromptSourceBuckets   = "buckets"   // 按输入token数分组的数据集目录，默认值
+	PromptSourceFile      = "file"      // jsonl 或 csv 文件，每条数据包含 prompt 和可选的期望输出token数
+	PromptSourceChat      = "chat"      // jsonl 文件，每条数据是一组对话消息
+	PromptSourceSynthetic = "synthetic" // 随机生成
+)
+
+const (
+	// DefaultDatasetDir 默认的按输入token数量分组的数据集目录
+	DefaultDatasetDir = "data/ShareGPT_V3_unfiltered_cleaned_split"
+	// DatasetFileFormat 分组后的数据集文件名
+	DatasetFileFormat = "input_tokens_%d.json"
+	// DefaultPromptPrefix 默认添加在 prompt 前面的文本
+	DefaultPromptPrefix = "Please provide a comprehensive and detailed response based on the following information: "
+	// DefaultSystemPrompt 对话接口默认的系统提示
+	DefaultSystemPrompt = "You are a helpful assistant."
+	// defaultSyntheticCount 默认随机生成的 prompt 数量
+	defaultSyntheticCount = 1000
+)
+
+// PromptConfig prompt 来源配置
+type PromptConfig struct {
+	Source       string  `yaml:"source"`       // 来源：buckets（默认）、file、chat、synthetic
+	Path         string  `yaml:"path"`         // buckets 的目录，或 file、chat 的文件路径
+	Prefix       *string `yaml:"prefix"`       // 添加在 prompt 前面的文本，不设置时使用默认值，设置为空字符串时不添加，对 chat 无效
+	SystemPrompt *string `yaml:"systemPrompt"` // 对话接口的系统提示，不设置时使用默认值，设置为空字符串时不添加
+	Count        int     `yaml:"count"`        // synthetic 生成的 prompt 数量
+	Tokenizer    string  `yaml:"tokenizer"`    // huggingface 格式的 tokenizer.json 路径，设置后 synthetic 生成的 prompt 加上 prefix 恰好为 inputTokens 个token，trt 没有返回 usage 时也用于统计输出token数
+}
+
+// GetSource 返回 prompt 来源，未设置时为 buckets
+func (p *PromptConfig) GetSource() string {
+	if p.Source == "" {
+		return PromptSourceBuckets
+	}
+	return p.Source
+}
+
+// BucketDir 返回按输入token数分组的数据集目录
+func (p *PromptConfig) BucketDir() string {
+	if p.Path == "" {
+		return DefaultDatasetDir
+	}
+	return p.Path
+}
+
+// BucketPath 返回给定输入token数对应的数据集文件路径
+func (p *PromptConfig) BucketPath(inputTokens int) string {
+	return filepath.Join(p.BucketDir(), fmt.Sprintf(DatasetFileFormat, inputTokens))
+}
+
+// GetPrefix 返回添加在 prompt 前面的文本
+func (p *PromptConfig) GetPrefix() string {
+	if p.Prefix == nil {
+		return DefaultPromptPrefix
+	}
+	return *p.Prefix
+}
+
+// GetSystemPrompt 返回对话接口的系统提示
+func (p *PromptConfig) GetSystemPrompt() string {
+	if p.SystemPrompt == nil {
+		return DefaultSystemPrompt
+	}
+	return *p.SystemPrompt
+}
+
+// GetCount 返回 synthetic 生成的 prompt 数量
+func (p *PromptConfig) GetCount() int {
+	if p.Count <= 0 {
+		return defaultSyntheticCount
+	}
+	return p.Count
+}
@@ -0,0 +1,46 @@
+package config
+
+// SamplingConfig 采样参数，不设置的参数使用推理服务的默认值，推理后端不支持的参数会在校验配置时报错
+type SamplingConfig struct {
+	Temperature       *float32 `yaml:"temperature"`       // 设置后覆盖顶层的 temperature，可以设置为 0 进行贪心解码
+	TopP              *float32 `yaml:"topP"`              // 核采样的累计概率
+	TopK              *int     `yaml:"topK"`              // 只从概率最高的 topK 个token中采样
+	Seed              *int     `yaml:"seed"`              // 随机种子
+	RepetitionPenalty *float32 `yaml:"repetitionPenalty"` // 重复惩罚，1 表示不惩罚
+	PresencePenalty   *float32 `yaml:"presencePenalty"`   // 存在惩罚
+	FrequencyPenalty  *float32 `yaml:"frequencyPenalty"`  // 频率惩罚
+	N                 int      `yaml:"n"`                 // 每条请求返回的序列数
+	BestOf            int      `yaml:"bestOf"`            // 生成 bestOf 个序列后返回最好的 n 个
+	BeamWidth         int      `yaml:"beamWidth"`         // beam search 的宽度，大于 1 时开启 beam search
+	MinTokens         int      `yaml:"minTokens"`         // 最少生成的token数
+}
+
+// Fields 返回设置了的采样参数的配置名称
+func (s *SamplingConfig) Fields() []string {
+	var fields []string
+	set := func(name string, ok bool) {
+		if ok {
+			fields = append(fields, name)
+		}
+	}
+	set("temperature", s.Temperature != nil)
+	set("topP", s.TopP != nil)
+	set("topK", s.TopK != nil)
+	set("seed", s.Seed != nil)
+	set("repetitionPenalty", s.RepetitionPenalty != nil)
+	set("presencePenalty", s.PresencePenalty != nil)
+	set("frequencyPenalty", s.FrequencyPenalty != nil)
+	set("n", s.N != 0)
+	set("bestOf", s.BestOf != 0)
+	set("beamWidth", s.BeamWidth != 0)
+	set("minTokens", s.MinTokens != 0)
+	return fields
+}
+
+// GetN 返回每条请求返回的序列数，默认为 1
+func (s *SamplingConfig) GetN() int {
+	if s.N <= 0 {
+		return 1
+	}
+	return s.N
+}
@@ -0,0 +1,25 @@
+package config
+
+// defaultUserTokens 随机生成的用户消息默认单词数
+const defaultUserTokens = 100
+
+// SessionConfig 多轮对话负载，每个虚拟用户进行多轮对话，每轮在上一轮的对话后追加模型回复和新的用户消息
+type SessionConfig struct {
+	Turns      int    `yaml:"turns"`      // 每个会话的轮数，大于 0 时开启多轮对话负载
+	ThinkTime  int    `yaml:"thinkTime"`  // 收到回复后到发送下一轮的间隔，单位为毫秒
+	Path       string `yaml:"path"`       // ShareGPT 格式的多轮对话文件，使用其中用户的消息，为空时随机生成
+	UserTokens int    `yaml:"userTokens"` // 随机生成的用户消息单词数
+}
+
+// Enabled 是否开启多轮对话负载
+func (s *SessionConfig) Enabled() bool {
+	return s.Turns > 0
+}
+
+// GetUserTokens 返回随机生成的用户消息单词数
+func (s *SessionConfig) GetUserTokens() int {
+	if s.UserTokens <= 0 {
+		return defaultUserTokens
+	}
+	return s.UserTokens
+}
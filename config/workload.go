@@ -0,0 +1,68 @@
+package config
+
+// 长度分布的类型
+const (
+	DistFixed     = "fixed"     // 固定值 value
+	DistUniform   = "uniform"   // [min, max] 之间均匀分布
+	DistLognormal = "lognormal" // 中位数为 median、对数标准差为 sigma 的对数正态分布，按 [min, max] 截断
+	DistHistogram = "histogram" // 从文件读取的经验分布，每行为 "长度 权重"
+)
+
+// Distribution 长度分布，type 为空时使用配置中的 inputTokens 或 maxTokens
+type Distribution struct {
+	Type   string  `yaml:"type"`   // fixed、uniform、lognormal、histogram
+	Value  int     `yaml:"value"`  // fixed 的取值
+	Min    int     `yaml:"min"`    // uniform 的下界，lognormal 的截断下界
+	Max    int     `yaml:"max"`    // uniform 的上界，lognormal 的截断上界
+	Median float64 `yaml:"median"` // lognormal 的中位数
+	Sigma  float64 `yaml:"sigma"`  // lognormal 的对数标准差
+	File   string  `yaml:"file"`   // histogram 的文件路径
+}
+
+// WorkloadClass 负载中的一类请求
+type WorkloadClass struct {
+	Name   string       `yaml:"name"`   // 类别名称，用于分类统计
+	Weight float64      `yaml:"weight"` // 该类请求所占的权重
+	Input  Distribution `yaml:"input"`  // 输入token数的分布，会取最接近的数据集分组或随机生成 prompt 的长度
+	Output Distribution `yaml:"output"` // 最大输出token数的分布
+}
+
+// WorkloadConfig 混合长度的负载，每条请求先按权重选择类别，再按类别的分布采样输入和输出长度
+type WorkloadConfig struct {
+	Seed    int64           `yaml:"seed"`    // 随机种子，相同的种子和并发度采样结果相同
+	Classes []WorkloadClass `yaml:"classes"` // 请求类别
+}
+
+// Empty 是否没有设置混合负载
+func (w *WorkloadConfig) Empty() bool {
+	return len(w.Classes) == 0
+}
+
+// SharedPrefixConfig 共享前缀的负载，每条请求的 prompt 前面添加同一段前缀，用于测试推理服务 prefix caching 的收益
+type SharedPrefixConfig struct {
+	Tokens  int  `yaml:"tokens"`  // 共享前缀的token数，大于 0 时开启
+	Control bool `yaml:"control"` // 每个并发度先进行一轮前缀各不相同的对照测试，统计结果中给出缓存带来的提升
+}
+
+// Enabled 是否开启共享前缀的负载
+func (s *SharedPrefixConfig) Enabled() bool {
+	return s.Tokens > 0
+}
+
+// eos 模式，用于按是否忽略 eos 分组统计
+const (
+	EosForced  = "forced"  // 忽略 eos，强制生成目标长度
+	EosNatural = "natural" // 遇到 eos 时停止，目标长度只是上限
+)
+
+// OutputConfig 目标输出长度和是否忽略 eos，设置后每条请求的最大输出token数按参考回答长度或分布采样
+type OutputConfig struct {
+	Reference   bool         `yaml:"reference"`   // 使用数据集中参考回答的token数作为目标长度，没有参考长度的 prompt 按 length 采样或使用 maxTokens
+	Length      Distribution `yaml:"length"`      // 目标长度的分布
+	ForcedRatio *float64     `yaml:"forcedRatio"` // 忽略 eos 强制生成目标长度的请求比例，取值 [0, 1]，不设置时所有请求都忽略 eos
+}
+
+// Toggled 是否按比例切换每条请求是否忽略 eos
+func (o *OutputConfig) Toggled() bool {
+	return o.ForcedRatio != nil
+}
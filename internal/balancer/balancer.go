@@ -0,0 +1,146 @@
+package balancer
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nullxjx/llm_profiler/config"
+)
+
+// Endpoint 一个推理服务地址，记录本轮的发送、进行中和失败请求数
+type Endpoint struct {
+	Url    string
+	Weight int
+
+	sent     atomic.Int32
+	inflight atomic.Int32
+	failed   atomic.Int32
+	current  int // 平滑加权轮询的当前权重
+}
+
+// Sent 返回本轮发送到该地址的请求数
+func (e *Endpoint) Sent() int32 {
+	return e.sent.Load()
+}
+
+// Failed 返回本轮该地址的失败请求数
+func (e *Endpoint) Failed() int32 {
+	return e.failed.Load()
+}
+
+// Fail 记录一条失败请求
+func (e *Endpoint) Fail() {
+	e.failed.Add(1)
+}
+
+// Balancer 在多个推理服务地址之间按权重分配请求
+type Balancer struct {
+	strategy  string
+	endpoints []*Endpoint
+	total     int // 权重之和
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// New 根据配置创建负载均衡器，没有配置多个地址时返回 nil
+func New(cfg *config.Config) (*Balancer, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, nil
+	}
+	b := &Balancer{
+		strategy: cfg.GetLoadBalance(),
+		rand:     rand.New(rand.NewSource(cfg.Workload.Seed)),
+	}
+	switch b.strategy {
+	case config.BalanceRoundRobin, config.BalanceLeastInflight, config.BalanceRandom:
+	default:
+		return nil, fmt.Errorf("unsupported load balance strategy %q", b.strategy)
+	}
+	for _, e := range cfg.Endpoints {
+		weight := e.GetWeight()
+		b.endpoints = append(b.endpoints, &Endpoint{Url: strings.TrimSuffix(e.Url, "/"), Weight: weight})
+		b.total += weight
+	}
+	return b, nil
+}
+
+// Endpoints 返回所有地址
+func (b *Balancer) Endpoints() []*Endpoint {
+	return b.endpoints
+}
+
+// Reset 在每轮开始时清空各地址的请求数
+func (b *Balancer) Reset() {
+	for _, e := range b.endpoints {
+		e.sent.Store(0)
+		e.failed.Store(0)
+		e.current = 0
+	}
+}
+
+// Acquire 为一条请求选择地址，请求结束后需要调用 Release
+func (b *Balancer) Acquire() *Endpoint {
+	b.mu.Lock()
+	var e *Endpoint
+	switch b.strategy {
+	case config.BalanceLeastInflight:
+		e = b.leastInflight()
+	case config.BalanceRandom:
+		e = b.random()
+	default:
+		e = b.roundRobin()
+	}
+	e.sent.Add(1)
+	e.inflight.Add(1)
+	b.mu.Unlock()
+	return e
+}
+
+// Release 标记请求结束
+func (b *Balancer) Release(e *Endpoint) {
+	e.inflight.Add(-1)
+}
+
+// roundRobin 平滑加权轮询，与 nginx 的实现一致，权重高的地址不会连续被选中
+func (b *Balancer) roundRobin() *Endpoint {
+	var best *Endpoint
+	for _, e := range b.endpoints {
+		e.current += e.Weight
+		if best == nil || e.current > best.current {
+			best = e
+		}
+	}
+	best.current -= b.total
+	return best
+}
+
+// leastInflight 选择进行中请求数与权重之比最小的地址，相同时选择已发送请求数与权重之比最小的地址，
+// 这样负载较低、没有进行中的请求时仍按权重分配
+func (b *Balancer) leastInflight() *Endpoint {
+	var best *Endpoint
+	var bestLoad, bestSent float64
+	for _, e := range b.endpoints {
+		load := float64(e.inflight.Load()) / float64(e.Weight)
+		sent := float64(e.sent.Load()) / float64(e.Weight)
+		if best == nil || load < bestLoad || (load == bestLoad && sent < bestSent) {
+			best, bestLoad, bestSent = e, load, sent
+		}
+	}
+	return best
+}
+
+// random 按权重随机选择
+func (b *Balancer) random() *Endpoint {
+	x := b.rand.Intn(b.total)
+	for _, e := range b.endpoints {
+		if x < e.Weight {
+			return e
+		}
+		x -= e.Weight
+	}
+	return b.endpoints[len(b.endpoints)-1]
+}
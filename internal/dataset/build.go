@@ -0,0 +1,159 @@
+package dataset
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/tokenizer"
+	"github.com/nullxjx/llm_profiler/internal/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BuildOptions 构建数据集的参数
+type BuildOptions struct {
+	Input     string  // 语料文件路径
+	Format    string  // 语料格式
+	Tokenizer string  // tokenizer.json 文件路径
+	OutputDir string  // 分组文件的保存目录
+	Buckets   []int   // 分组的 token 数
+	Tolerance float64 // token 数与分组的最大相对差距，例如 0.05 表示 5% 以内
+	MaxCount  int     // 每个分组最多保留的 prompt 数，0 表示不限制
+}
+
+// indexedSample 语料中的一条 prompt 及其在语料中的序号
+type indexedSample struct {
+	index int
+	Sample
+}
+
+// indexedInput 分组中的一条 prompt 及其在语料中的序号
+type indexedInput struct {
+	index int
+	utils.Input
+}
+
+// Buckets 生成从 step 到 max 步长为 step 的分组
+func Buckets(step, max int) []int {
+	var buckets []int
+	for b := step; step > 0 && b <= max; b += step {
+		buckets = append(buckets, b)
+	}
+	return buckets
+}
+
+// Build 使用给定的 tokenizer 统计语料中每条 prompt 的 token 数，按最接近的分组保存，返回每个分组的 prompt 数
+func Build(opts *BuildOptions) (map[int]int, error) {
+	if len(opts.Buckets) == 0 {
+		return nil, fmt.Errorf("no buckets")
+	}
+	tok, err := tokenizer.Load(opts.Tokenizer)
+	if err != nil {
+		return nil, fmt.Errorf("load tokenizer error: %v", err)
+	}
+	buckets := append([]int(nil), opts.Buckets...)
+	sort.Ints(buckets)
+
+	samples := make(chan indexedSample, 1024)
+	groups := make(map[int][]indexedInput)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range samples {
+				tokens := tok.Count(s.Prompt)
+				bucket, ok := nearest(buckets, tokens, opts.Tolerance)
+				if !ok {
+					continue
+				}
+				input := indexedInput{index: s.index, Input: utils.Input{Prompt: s.Prompt, Tokens: tokens}}
+				if s.Output != "" {
+					input.OutputTokens = tok.Count(s.Output)
+				}
+				mu.Lock()
+				groups[bucket] = append(groups[bucket], input)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	total := 0
+	err = Read(opts.Input, opts.Format, func(s Sample) error {
+		if strings.TrimSpace(s.Prompt) == "" {
+			return nil
+		}
+		total++
+		if total%10000 == 0 {
+			log.Infof("%v prompts processed", total)
+		}
+		samples <- indexedSample{index: total, Sample: s}
+		return nil
+	})
+	close(samples)
+	wg.Wait()
+	if err != nil {
+		return nil, fmt.Errorf("read %s error: %v", opts.Input, err)
+	}
+	log.Infof("%v prompts processed", total)
+
+	counts := make(map[int]int)
+	for bucket, group := range groups {
+		// 多个协程处理导致顺序不确定，先按语料中的顺序保留前 MaxCount 条，与单协程处理的结果一致
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].index < group[j].index
+		})
+		if opts.MaxCount > 0 && len(group) > opts.MaxCount {
+			group = group[:opts.MaxCount]
+		}
+		inputs := make([]utils.Input, len(group))
+		for i := range group {
+			inputs[i] = group[i].Input
+		}
+		// 再按 token 数排序使保存的文件可复现
+		sort.SliceStable(inputs, func(i, j int) bool {
+			if inputs[i].Tokens != inputs[j].Tokens {
+				return inputs[i].Tokens < inputs[j].Tokens
+			}
+			return inputs[i].Prompt < inputs[j].Prompt
+		})
+		path := filepath.Join(opts.OutputDir, fmt.Sprintf(config.DatasetFileFormat, bucket))
+		if err = utils.WriteJson(inputs, path); err != nil {
+			return nil, fmt.Errorf("save %s error: %v", path, err)
+		}
+		counts[bucket] = len(inputs)
+	}
+	return counts, nil
+}
+
+// nearest 返回与 tokens 最接近且相对差距在 tolerance 以内的分组
+func nearest(buckets []int, tokens int, tolerance float64) (int, bool) {
+	i := sort.SearchInts(buckets, tokens)
+	best := -1
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(buckets) {
+			continue
+		}
+		if best < 0 || abs(buckets[j]-tokens) < abs(best-tokens) {
+			best = buckets[j]
+		}
+	}
+	if best <= 0 || math.Abs(float64(tokens-best)) > float64(best)*tolerance {
+		return 0, false
+	}
+	return best, true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,134 @@
+package dataset
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// 支持的语料格式
+const (
+	FormatShareGPT = "sharegpt" // [{"conversations": [{"from": "human", "value": "..."}, {"from": "gpt", "value": "..."}]}]
+	FormatAlpaca   = "alpaca"   // [{"instruction": "...", "input": "...", "output": "..."}]
+	FormatJsonl    = "jsonl"    // 每行一个 {"prompt": "...", "output": "..."}，output 可选
+)
+
+// Sample 语料中的一条数据
+type Sample struct {
+	Prompt string // 输入
+	Output string // 参考回答，可能为空
+}
+
+type shareGPTItem struct {
+	Conversations []struct {
+		From  string `json:"from"`
+		Value string `json:"value"`
+	} `json:"conversations"`
+}
+
+type alpacaItem struct {
+	Instruction string `json:"instruction"`
+	Input       string `json:"input"`
+	Output      string `json:"output"`
+}
+
+type jsonlItem struct {
+	Prompt     string `json:"prompt"`
+	Output     string `json:"output"`
+	Completion string `json:"completion"`
+}
+
+// Read 逐条读取语料，大文件也不会整体加载到内存
+func Read(path, format string, fn func(Sample) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch format {
+	case FormatShareGPT:
+		return readArray(file, func(item *shareGPTItem) error {
+			// 与 vllm 的 benchmark 一致，取第一轮对话，后一条回复作为参考回答
+			c := item.Conversations
+			if len(c) < 2 || c[0].From != "human" {
+				return nil
+			}
+			return fn(Sample{Prompt: c[0].Value, Output: c[1].Value})
+		})
+	case FormatAlpaca:
+		return readArray(file, func(item *alpacaItem) error {
+			prompt := item.Instruction
+			if item.Input != "" {
+				prompt += "\n\n" + item.Input
+			}
+			return fn(Sample{Prompt: prompt, Output: item.Output})
+		})
+	case FormatJsonl:
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var item jsonlItem
+			if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+				return fmt.Errorf("line %d: %v", line, err)
+			}
+			output := item.Output
+			if output == "" {
+				output = item.Completion
+			}
+			if err := fn(Sample{Prompt: item.Prompt, Output: output}); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	default:
+		return fmt.Errorf("unsupported format %q, supported: %s, %s, %s",
+			format, FormatShareGPT, FormatAlpaca, FormatJsonl)
+	}
+}
+
+// ReadConversations 逐条读取 ShareGPT 格式语料中的多轮对话，返回每个对话中用户的消息
+func ReadConversations(path string, fn func([]string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return readArray(file, func(item *shareGPTItem) error {
+		var turns []string
+		for _, c := range item.Conversations {
+			if c.From == "human" && strings.TrimSpace(c.Value) != "" {
+				turns = append(turns, c.Value)
+			}
+		}
+		if len(turns) == 0 {
+			return nil
+		}
+		return fn(turns)
+	})
+}
+
+// readArray 流式解析 json 数组中的每个元素
+func readArray[T any](r io.Reader, fn func(*T) error) error {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	for i := 0; decoder.More(); i++ {
+		var item T
+		if err := decoder.Decode(&item); err != nil {
+			return fmt.Errorf("item %d: %v", i, err)
+		}
+		if err := fn(&item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/nullxjx/llm_profiler/internal/infer/param"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+const namespace = "llm_profiler"
+
+// 错误分类，作为失败请求计数的 error_class 标签
+const (
+	ErrorTimeout    = "timeout"
+	ErrorConnection = "connection"
+	ErrorClient     = "http_4xx"
+	ErrorServer     = "http_5xx"
+	ErrorDecode     = "decode"
+	ErrorOther      = "other"
+)
+
+var statusCodePattern = regexp.MustCompile(`status code: (\d{3})`)
+
+var (
+	inflight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "inflight_requests",
+		Help:      "Number of requests sent and not yet finished.",
+	})
+	sent = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_sent_total",
+		Help:      "Total number of requests sent.",
+	})
+	success = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_success_total",
+		Help:      "Total number of successful requests.",
+	})
+	failed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_failed_total",
+		Help:      "Total number of failed requests by error class.",
+	}, []string{"error_class"})
+	outputTokens = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "output_tokens_total",
+		Help:      "Total number of output tokens of successful requests.",
+	})
+	latency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_latency_seconds",
+		Help:      "Client side latency of successful requests.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	})
+	firstToken = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "first_token_seconds",
+		Help:      "Time to first token of successful stream requests.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+	concurrency = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "concurrency",
+		Help:      "Current concurrency level, i.e. requests sent per duration.",
+	})
+)
+
+// Serve 在 addr 上暴露 /metrics 接口，监听失败只打印日志，不影响压测
+func Serve(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		log.Infof("Serving prometheus metrics at %v/metrics", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Errorf("serve prometheus metrics error: %v", err)
+		}
+	}()
+}
+
+// SetConcurrency 记录当前轮次的并发度
+func SetConcurrency(c int) {
+	concurrency.Set(float64(c))
+}
+
+// RequestSent 记录一条请求被发出
+func RequestSent() {
+	sent.Inc()
+	inflight.Inc()
+}
+
+// RequestDone 记录一条请求结束，无论成功与否
+func RequestDone() {
+	inflight.Dec()
+}
+
+// RequestSucceeded 记录一条成功请求的延迟和输出token数
+func RequestSucceeded(result *param.Result) {
+	success.Inc()
+	outputTokens.Add(float64(result.OutputTokens))
+	latency.Observe(float64(result.TimeSpent) / 1000)
+	if result.FirstTokenTime > 0 {
+		firstToken.Observe(result.FirstTokenTime / 1000)
+	}
+}
+
+// RequestFailed 按错误分类记录一条失败请求
+func RequestFailed(err error) {
+	failed.WithLabelValues(ErrorClass(err)).Inc()
+}
+
+// ErrorClass 对请求错误进行分类
+func ErrorClass(err error) string {
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return ErrorTimeout
+		}
+		return ErrorConnection
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return ErrorDecode
+	}
+	// 流式接口的错误经过了字符串拼接，只能从错误信息中解析状态码
+	if matches := statusCodePattern.FindStringSubmatch(err.Error()); len(matches) == 2 {
+		code, _ := strconv.Atoi(matches[1])
+		if code >= 500 {
+			return ErrorServer
+		}
+		return ErrorClient
+	}
+	return ErrorOther
+}
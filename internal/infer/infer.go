@@ -1,198 +1,285 @@
 package infer
 
 import (
-	"context"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/exporter"
 	"github.com/nullxjx/llm_profiler/internal/infer/param"
 	"github.com/nullxjx/llm_profiler/internal/infer/stream"
 	"github.com/nullxjx/llm_profiler/internal/infer/tgi"
 	"github.com/nullxjx/llm_profiler/internal/infer/triton"
+	"github.com/nullxjx/llm_profiler/internal/infer/type/backend"
 	"github.com/nullxjx/llm_profiler/internal/infer/vllm"
+	"github.com/nullxjx/llm_profiler/internal/tokenizer"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// SendVllmRequest 发送 vllm 请求
-func SendVllmRequest(req *param.RequestParam) {
-	defer req.Wg.Done()
-	atomic.AddInt32(&req.Counter.Total, 1)
+// canceled 判断请求是否因测试中断而被取消，是的话计入取消数
+func canceled(req *param.RequestParam) bool {
+	if req.Ctx.Err() == nil {
+		return false
+	}
+	atomic.AddInt32(&req.Counter.Canceled, 1)
+	return true
+}
+
+// fail 记录一条失败请求
+func fail(req *param.RequestParam, err error) {
+	log.Errorf("😭😭😭 infer error: %v", err)
+	atomic.AddInt32(&req.Counter.Failed, 1)
+	if req.Endpoint != nil {
+		req.Endpoint.Fail()
+	}
+	if !req.Candidate {
+		exporter.RequestFailed(err)
+	}
+}
+
+// url 返回请求发送的地址
+func url(req *param.RequestParam) string {
+	if req.Endpoint != nil {
+		return req.Endpoint.Url
+	}
+	return config.GetUrl(req.Config)
+}
+
+// maxTokens 返回请求的最大输出token数，按负载分布采样了输出长度时使用采样值
+func maxTokens(req *param.RequestParam) uint32 {
+	if req.MaxTokens > 0 {
+		return req.MaxTokens
+	}
+	return req.Config.MaxTokens
+}
+
+// inferParams 根据配置和请求构造推理参数
+func inferParams(req *param.RequestParam) *param.InferParams {
 	cfg := req.Config
-	result, err := vllm.CompletionByVLLM(&param.InferParams{
-		PromptList:   []string{req.Prompt},
-		ModelName:    cfg.Model.Name,
+	model, adapter := cfg.Model.Name, ""
+	if req.Model != "" && req.Model != cfg.Model.Name {
+		model, adapter = req.Model, req.Model
+	}
+	return &param.InferParams{
+		PromptList:   []string{req.Prompt.Text},
+		Messages:     req.Prompt.Messages,
+		ModelName:    model,
 		ModelVersion: cfg.Model.Version,
+		Adapter:      adapter,
 		Timeout:      cfg.RequestTimeout,
 		InferConfig: &param.InferConfig{
 			StopWords:   cfg.StopWords,
-			MaxTokens:   cfg.MaxTokens,
+			MaxTokens:   maxTokens(req),
 			Temperature: cfg.Temperature,
+			Sampling:    &cfg.Sampling,
+			StopAtEos:   req.StopAtEos,
 		},
-	}, config.GetUrl(cfg))
+	}
+}
+
+// newResult 创建一条请求结果，填充与推理后端无关的字段
+func newResult(req *param.RequestParam) param.Result {
+	res := param.Result{
+		Prompt:             req.Prompt.Text,
+		InputLen:           len(req.Prompt.Text),
+		DispatchLag:        req.DispatchLag,
+		Class:              req.Class,
+		TargetInputTokens:  req.TargetInputTokens,
+		TargetOutputTokens: int(req.MaxTokens),
+		Session:            req.Session,
+		Turn:               req.Turn,
+		EosMode:            req.EosMode,
+		Pair:               req.Pair,
+		Model:              req.Model,
+	}
+	if req.Endpoint != nil {
+		res.Endpoint = req.Endpoint.Url
+	}
+	return res
+}
+
+// handlers 各推理后端非流式请求的发送函数
+var handlers = map[string]func(*param.RequestParam){
+	string(backend.VLLM): SendVllmRequest,
+	string(backend.TRT):  SendTrtRequest,
+	string(backend.TGI):  SendTgiRequest,
+}
+
+// streamHandlers 各推理后端流式请求的发送函数
+var streamHandlers = map[string]func(*param.RequestParam){
+	string(backend.VLLM): SendVllmStreamRequest,
+	string(backend.TRT):  SendTrtStreamRequest,
+}
+
+// Handler 返回推理后端发送请求的函数，后端不支持时返回 false
+func Handler(name string, stream bool) (func(*param.RequestParam), bool) {
+	if stream {
+		h, ok := streamHandlers[strings.ToLower(name)]
+		return h, ok
+	}
+	h, ok := handlers[strings.ToLower(name)]
+	return h, ok
+}
+
+// SendVllmRequest 发送 vllm 请求
+func SendVllmRequest(req *param.RequestParam) {
+	defer req.Wg.Done()
+	atomic.AddInt32(&req.Counter.Total, 1)
+	result, err := vllm.CompletionByVLLM(req.Ctx, inferParams(req), url(req))
 	if err != nil {
-		log.Errorf("😭😭😭 infer error: %v", err)
-		atomic.AddInt32(&req.Counter.Failed, 1)
+		if canceled(req) {
+			return
+		}
+		fail(req, err)
 		return
 	}
 
 	atomic.AddInt32(&req.Counter.Success, 1)
-	req.Result <- param.Result{
-		Prompt:       req.Prompt,
-		InputLen:     len(req.Prompt),
-		InputTokens:  result[0].InputTokens,
-		Output:       result[0].Result,
-		OutputLen:    len(result[0].Result),
-		OutputTokens: result[0].OutputTokens,
-		TimeSpent:    result[0].TimeSpent,
-	}
+	res := newResult(req)
+	res.InputTokens = result[0].InputTokens
+	res.Output = result[0].Result
+	res.OutputLen = len(result[0].Result)
+	res.OutputTokens = result[0].OutputTokens
+	res.Sequences = result[0].Sequences
+	res.TimeSpent = result[0].TimeSpent
+	req.Result <- res
 }
 
 // SendVllmStreamRequest 发送 vllm 流式请求
 func SendVllmStreamRequest(req *param.RequestParam) {
 	defer req.Wg.Done()
 	atomic.AddInt32(&req.Counter.Total, 1)
-	cfg := req.Config
 	start := time.Now()
-	url := config.GetUrl(cfg)
-	s, err := vllm.StreamChatByVLLM(context.Background(), url,
-		&param.InferParams{
-			PromptList:   []string{req.Prompt},
-			ModelName:    cfg.Model.Name,
-			ModelVersion: cfg.Model.Version,
-			Timeout:      cfg.RequestTimeout,
-			InferConfig: &param.InferConfig{
-				StopWords:   cfg.StopWords,
-				MaxTokens:   cfg.MaxTokens,
-				Temperature: cfg.Temperature,
-			},
-		})
+	s, err := vllm.StreamChatByVLLM(req.Ctx, url(req), inferParams(req))
 	if err != nil {
-		log.Errorf("😭😭😭 infer error: %v", err)
-		atomic.AddInt32(&req.Counter.Failed, 1)
+		if canceled(req) {
+			return
+		}
+		fail(req, err)
 		return
 	}
 	metrics := stream.CalVllmMetrics(s, start)
-	if metrics.OutputTokens >= int(req.Config.MaxTokens) {
+	if canceled(req) { // 中断时流式输出不完整，结果不计入统计
+		return
+	}
+	if metrics.OutputTokens >= int(maxTokens(req)) {
 		log.Debugf("stream output tokens: %d, time: %.1f s, speed: %.1f tokens/s, first_token: %.1f ms",
 			metrics.OutputTokens, metrics.TimeSpentSeconds, metrics.TokensPerSec, metrics.FirstTokenTime)
 	}
 	atomic.AddInt32(&req.Counter.Success, 1)
-	req.Result <- param.Result{
-		Prompt:          req.Prompt,
-		InputLen:        len(req.Prompt),
-		OutputTokens:    metrics.OutputTokens,
-		TimeSpent:       time.Now().Sub(start).Milliseconds(),
-		TokensPerSecond: metrics.TokensPerSec,
-		FirstTokenTime:  metrics.FirstTokenTime,
-	}
+	res := newResult(req)
+	res.InputTokens = metrics.InputTokens
+	res.Output = metrics.Output
+	res.OutputLen = len(metrics.Output)
+	res.OutputTokens = metrics.OutputTokens
+	res.Sequences = metrics.Sequences
+	res.TimeSpent = time.Now().Sub(start).Milliseconds()
+	res.TokensPerSecond = metrics.TokensPerSec
+	res.FirstTokenTime = metrics.FirstTokenTime
+	req.Result <- res
 }
 
 // SendTgiRequest 发送 Tgi 请求
 func SendTgiRequest(req *param.RequestParam) {
 	defer req.Wg.Done()
 	atomic.AddInt32(&req.Counter.Total, 1)
-	cfg := req.Config
 	start := time.Now()
-	result, err := tgi.InferTGI(&param.InferParams{
-		PromptList:   []string{req.Prompt},
-		ModelName:    cfg.Model.Name,
-		ModelVersion: cfg.Model.Version,
-		Timeout:      cfg.RequestTimeout,
-		InferConfig: &param.InferConfig{
-			StopWords:   cfg.StopWords,
-			MaxTokens:   cfg.MaxTokens,
-			Temperature: cfg.Temperature,
-		},
-	}, config.GetUrl(cfg))
+	result, err := tgi.InferTGI(req.Ctx, inferParams(req), url(req))
 	if err != nil {
-		log.Errorf("😭😭😭 infer error: %v", err)
-		atomic.AddInt32(&req.Counter.Failed, 1)
+		if canceled(req) {
+			return
+		}
+		fail(req, err)
 		return
 	}
 	atomic.AddInt32(&req.Counter.Success, 1)
-	req.Result <- param.Result{
-		Prompt:       req.Prompt,
-		InputLen:     len(req.Prompt),
-		InputTokens:  result[0].InputTokens,
-		Output:       result[0].Result,
-		OutputLen:    len(result[0].Result),
-		OutputTokens: result[0].OutputTokens,
-		TimeSpent:    time.Now().Sub(start).Milliseconds(),
-	}
+	res := newResult(req)
+	res.InputTokens = result[0].InputTokens
+	res.Output = result[0].Result
+	res.OutputLen = len(result[0].Result)
+	res.OutputTokens = result[0].OutputTokens
+	res.Sequences = result[0].Sequences
+	res.TimeSpent = time.Now().Sub(start).Milliseconds()
+	req.Result <- res
 }
 
 // SendTrtRequest 发送 TensorRT-LLM 请求
 func SendTrtRequest(req *param.RequestParam) {
 	defer req.Wg.Done()
 	atomic.AddInt32(&req.Counter.Total, 1)
-	cfg := req.Config
-	result, err := triton.InferTrt(&param.InferParams{
-		PromptList:   []string{req.Prompt},
-		ModelName:    cfg.Model.Name,
-		ModelVersion: cfg.Model.Version,
-		Timeout:      cfg.RequestTimeout,
-		InferConfig: &param.InferConfig{
-			StopWords:   cfg.StopWords,
-			MaxTokens:   cfg.MaxTokens,
-			Temperature: cfg.Temperature,
-		},
-	}, config.GetUrl(cfg))
+	result, err := triton.InferTrt(req.Ctx, inferParams(req), url(req))
 	if err != nil {
-		log.Errorf("😭😭😭 infer error: %v", err)
-		atomic.AddInt32(&req.Counter.Failed, 1)
+		if canceled(req) {
+			return
+		}
+		fail(req, err)
 		return
 	}
 	atomic.AddInt32(&req.Counter.Success, 1)
-	req.Result <- param.Result{
-		Prompt:       req.Prompt,
-		InputLen:     len(req.Prompt),
-		InputTokens:  result[0].InputTokens,
-		Output:       result[0].Result,
-		OutputLen:    len(result[0].Result),
-		OutputTokens: int(cfg.MaxTokens),
-		TimeSpent:    result[0].TimeSpent,
+	res := newResult(req)
+	res.InputTokens = result[0].InputTokens
+	res.Output = result[0].Result
+	res.OutputLen = len(result[0].Result)
+	res.Sequences = result[0].Sequences
+	res.OutputTokens = result[0].OutputTokens
+	if res.OutputTokens == 0 {
+		res.OutputTokens, res.OutputTokensUnknown = estimateOutputTokens(req, res.Output, res.Sequences)
 	}
+	res.TimeSpent = result[0].TimeSpent
+	req.Result <- res
+}
+
+// estimateOutputTokens 服务没有返回 usage 时估计输出token数：配置了 tokenizer 时统计输出的token数
+// （返回多个序列时只有第一个序列的文本，无法统计），否则忽略 eos 时按每个序列都生成了最大输出token数计算，
+// 遇到 eos 停止时实际长度未知，返回 unknown
+func estimateOutputTokens(req *param.RequestParam, output string, sequences int) (tokens int, unknown bool) {
+	if path := req.Config.Prompt.Tokenizer; path != "" && sequences <= 1 {
+		tok, err := tokenizer.LoadCached(path)
+		if err == nil {
+			return tok.Count(output), false
+		}
+		log.Debugf("load tokenizer %s error: %v", path, err)
+	}
+	if !req.StopAtEos {
+		return int(maxTokens(req)) * max(1, sequences), false
+	}
+	return 0, true
 }
 
 // SendTrtStreamRequest 发送 TensorRT-LLM 流式请求
 func SendTrtStreamRequest(req *param.RequestParam) {
 	defer req.Wg.Done()
 	atomic.AddInt32(&req.Counter.Total, 1)
-	cfg := req.Config
 
 	start := time.Now()
-	s, err := triton.StreamInferByTrt(context.Background(), config.GetUrl(cfg),
-		&param.InferParams{
-			PromptList:   []string{req.Prompt},
-			ModelName:    cfg.Model.Name,
-			ModelVersion: cfg.Model.Version,
-			Timeout:      cfg.RequestTimeout,
-			InferConfig: &param.InferConfig{
-				StopWords:   cfg.StopWords,
-				MaxTokens:   cfg.MaxTokens,
-				Temperature: cfg.Temperature,
-			},
-		})
+	s, err := triton.StreamInferByTrt(req.Ctx, url(req), inferParams(req))
 	if err != nil {
-		log.Errorf("😭😭😭 infer error: %v", err)
-		atomic.AddInt32(&req.Counter.Failed, 1)
+		if canceled(req) {
+			return
+		}
+		fail(req, err)
 		return
 	}
 
 	metrics := stream.CalTrtMetrics(s, start)
-	if metrics.OutputTokens >= int(cfg.MaxTokens) {
+	if canceled(req) { // 中断时流式输出不完整，结果不计入统计
+		return
+	}
+	if metrics.OutputTokens >= int(maxTokens(req)) {
 		log.Debugf("stream output tokens: %d, time: %.1f s, speed: %.1f tokens/s, first_token: %.1f ms",
 			metrics.OutputTokens, metrics.TimeSpentSeconds, metrics.TokensPerSec, metrics.FirstTokenTime)
 	}
 	atomic.AddInt32(&req.Counter.Success, 1)
-	req.Result <- param.Result{
-		Prompt:          req.Prompt,
-		InputLen:        len(req.Prompt),
-		OutputTokens:    metrics.OutputTokens,
-		TimeSpent:       time.Now().Sub(start).Milliseconds(),
-		TokensPerSecond: metrics.TokensPerSec,
-		FirstTokenTime:  metrics.FirstTokenTime,
-	}
+	res := newResult(req)
+	res.InputTokens = metrics.InputTokens
+	res.Output = metrics.Output
+	res.OutputLen = len(metrics.Output)
+	res.OutputTokens = metrics.OutputTokens
+	res.Sequences = metrics.Sequences
+	res.TimeSpent = time.Now().Sub(start).Milliseconds()
+	res.TokensPerSecond = metrics.TokensPerSec
+	res.FirstTokenTime = metrics.FirstTokenTime
+	req.Result <- res
 }
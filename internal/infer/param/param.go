@@ -1,40 +1,60 @@
 package param
 
 import (
+	"context"
 	"sync"
 
 	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/balancer"
+	"github.com/nullxjx/llm_profiler/internal/prompt"
 
 	"github.com/sashabaranov/go-openai"
 )
 
 type Counter struct {
-	Success int32
-	Failed  int32
-	Total   int32
+	Success  int32
+	Failed   int32
+	Total    int32
+	Canceled int32 // 因测试被中断而取消的请求数，不计入失败
 }
 type RequestParam struct {
-	Wg      *sync.WaitGroup
-	Result  chan<- Result
-	Prompt  string
-	Counter *Counter
-	Config  *config.Config
+	Ctx         context.Context // 测试被中断时取消，用于终止正在进行的请求
+	Wg          *sync.WaitGroup
+	Result      chan<- Result
+	Prompt      *prompt.Prompt
+	DispatchLag float64 // 实际发送时间比计划发送时间的延迟，单位毫秒
+	Counter     *Counter
+	Config      *config.Config
+	Balancer    *balancer.Balancer // 配置了多个副本地址时用于选择地址，为 nil 时使用 config.GetUrl
+	Endpoint    *balancer.Endpoint // 发送时选择的地址
+
+	Class             string // 按负载分布采样时请求所属的类别
+	TargetInputTokens int    // 按负载分布采样的输入token数，0 表示未采样
+	MaxTokens         uint32 // 按负载分布采样的最大输出token数，0 表示使用配置中的 maxTokens
+	Session           int    // 多轮对话负载中的会话编号，从 1 开始，0 表示不是多轮对话
+	Turn              int    // 多轮对话负载中的轮次，从 1 开始
+	StopAtEos         bool   // 遇到 eos 时停止生成，不强制生成 MaxTokens 个token
+	EosMode           string // 按比例切换是否忽略 eos 时为 forced 或 natural，用于分组统计
+	Pair              int    // A/B 对比测试中的配对编号，从 1 开始，两个服务的同一条请求编号相同
+	Model             string // 按模型混合选择的模型，为空时使用配置中的模型
+	Candidate         bool   // A/B 对比测试中发送到对比服务的请求，不计入 prometheus 指标
 }
 
 type InferConfig struct {
 	StopWords   []string
 	MaxTokens   uint32
-	TopK        uint32
-	TopP        float32
-	BeamWidth   uint32
 	Temperature float32
+	Sampling    *config.SamplingConfig // 其他采样参数，为 nil 时使用推理服务的默认值
+	StopAtEos   bool                   // 遇到 eos 时停止生成，默认忽略 eos 生成 MaxTokens 个token
 }
 
 type InferParams struct {
 	PromptList   []string
+	Messages     []prompt.Message // 对话接口的消息，为空时使用默认系统提示和 PromptList[0]
 	ModelName    string
 	ModelVersion string
-	Timeout      int // 超时时间，单位为毫秒
+	Adapter      string // 模型混合中选择了 LoRA adapter 时的名称，用于 tgi 的 adapter_id
+	Timeout      int    // 超时时间，单位为毫秒
 	InferConfig  *InferConfig
 }
 
@@ -45,22 +65,38 @@ type PromptSpentTime struct {
 
 // Result 该轮次调用结果记录
 type Result struct {
-	Prompt          string  `json:"prompt"`
+	Prompt          string  `json:"prompt,omitempty"`
+	PromptHash      string  `json:"promptHash,omitempty"` // 配置 resultText: hash 时只保存 prompt 的 sha256
 	InputLen        int     `json:"inputLen"`
 	InputTokens     int     `json:"inputTokens"`
-	Output          string  `json:"output"`
+	Output          string  `json:"output,omitempty"`
+	OutputHash      string  `json:"outputHash,omitempty"` // 配置 resultText: hash 时只保存输出的 sha256
 	OutputLen       int     `json:"outputLen"`
 	OutputTokens    int     `json:"outputTokens"`
 	TimeSpent       int64   `json:"timeSpent"`
 	TokensPerSecond float64 `json:"tokensPerSecond"` // 每秒输出token数目
 	FirstTokenTime  float64 `json:"firstTokenTime"`
+	DispatchLag     float64 `json:"dispatchLag"` // 实际发送时间比计划发送时间的延迟，单位毫秒
+
+	Class               string `json:"class,omitempty"`               // 按负载分布采样时请求所属的类别
+	TargetInputTokens   int    `json:"targetInputTokens,omitempty"`   // 采样的输入token数
+	TargetOutputTokens  int    `json:"targetOutputTokens,omitempty"`  // 采样的最大输出token数
+	Session             int    `json:"session,omitempty"`             // 多轮对话负载中的会话编号
+	Turn                int    `json:"turn,omitempty"`                // 多轮对话负载中的轮次，inputTokens 即为该轮的上下文长度
+	EosMode             string `json:"eosMode,omitempty"`             // forced 表示忽略 eos 生成目标长度，natural 表示遇到 eos 时停止
+	Sequences           int    `json:"sequences,omitempty"`           // 返回的序列数，outputTokens 为所有序列之和
+	Endpoint            string `json:"endpoint,omitempty"`            // 配置了多个副本地址时请求发送到的地址
+	Pair                int    `json:"pair,omitempty"`                // A/B 对比测试中的配对编号
+	Model               string `json:"model,omitempty"`               // 按模型混合选择的模型
+	OutputTokensUnknown bool   `json:"outputTokensUnknown,omitempty"` // 服务没有返回输出token数且无法计数，outputTokens 为 0，不计入输出token统计
 }
 
 type InferResult struct {
-	Result       string `json:"result"`
+	Result       string `json:"result"` // 返回多个序列时为第一个序列的文本
 	TimeSpent    int64  `json:"timeSpent"`
 	InputTokens  int    `json:"inputTokens"`
-	OutputTokens int    `json:"outputTokens"`
+	OutputTokens int    `json:"outputTokens"` // 所有序列的输出token数之和
+	Sequences    int    `json:"sequences"`    // 返回的序列数，n > 1 或 beam search 时大于 1
 }
 
 type InferRsp openai.CompletionResponse
@@ -0,0 +1,34 @@
+package infer
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/infer/tgi"
+	"github.com/nullxjx/llm_profiler/internal/infer/triton"
+	"github.com/nullxjx/llm_profiler/internal/infer/type/backend"
+	"github.com/nullxjx/llm_profiler/internal/infer/vllm"
+)
+
+// samplingFields 各推理后端支持的采样参数
+var samplingFields = map[string][]string{
+	string(backend.VLLM): vllm.SamplingFields,
+	string(backend.TGI):  tgi.SamplingFields,
+	string(backend.TRT):  triton.SamplingFields,
+}
+
+// UnsupportedSampling 返回配置中设置了但推理后端不支持的采样参数
+func UnsupportedSampling(name string, s *config.SamplingConfig) []string {
+	supported, ok := samplingFields[strings.ToLower(name)]
+	if !ok {
+		return nil
+	}
+	var unsupported []string
+	for _, field := range s.Fields() {
+		if !slices.Contains(supported, field) {
+			unsupported = append(unsupported, field)
+		}
+	}
+	return unsupported
+}
@@ -7,25 +7,45 @@ import (
 	"sync"
 	"time"
 
+	"github.com/nullxjx/llm_profiler/internal/infer/stream/postprocess"
+
 	"github.com/pkg/errors"
 	"github.com/sashabaranov/go-openai"
 )
 
 // StreamMetrics 流式输出相关的指标
 type StreamMetrics struct {
+	InputTokens      int // 服务端返回的输入token数，没有返回时为 0
 	OutputTokens     int
 	TokensPerSec     float64
 	FirstTokenTime   float64
 	TimeSpentSeconds float64
+	Output           string // 拼接后的输出文本，返回多个序列时为第一个序列
+	Sequences        int    // 返回的序列数
+}
+
+var dataPattern = regexp.MustCompile(`^data:\s*(\{.*})`)
+
+// vllmChunk vllm 流式补全和对话接口返回的 chunk
+type vllmChunk struct {
+	Choices []struct {
+		Index int    `json:"index"`
+		Text  string `json:"text"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openai.Usage `json:"usage"`
 }
 
 // CalVllmMetrics 计算 vllm stream infer 相关指标
 func CalVllmMetrics(stream <-chan []byte, startTime time.Time) *StreamMetrics {
 	var once sync.Once
 	var firstTokenTime float64 // 单位毫秒
+	var output strings.Builder
 
-	completionTokens := 0
-	count := -1
+	completionTokens, promptTokens := 0, 0
+	count, sequences := -1, 1
 	for data := range stream {
 		once.Do(func() {
 			firstTokenTime = float64(time.Now().Sub(startTime).Milliseconds())
@@ -35,21 +55,33 @@ func CalVllmMetrics(stream <-chan []byte, startTime time.Time) *StreamMetrics {
 		}
 		count += 1
 
-		tokens, _, err := getVllmChatStreamTokens(string(data))
+		chunk, err := parseVllmChunk(string(data))
 		if err != nil {
 			continue
 		}
-		if tokens > 1 {
-			completionTokens = tokens
+		// n > 1 时多个序列的 chunk 交替返回，usage 中的输出token数为所有序列之和
+		for _, choice := range chunk.Choices {
+			sequences = max(sequences, choice.Index+1)
+			if choice.Index == 0 {
+				output.WriteString(choice.Text)
+				output.WriteString(choice.Delta.Content)
+			}
+		}
+		if chunk.Usage != nil && chunk.Usage.CompletionTokens > 1 {
+			completionTokens = chunk.Usage.CompletionTokens
+			promptTokens = chunk.Usage.PromptTokens
 		}
 	}
 	timeSpentSeconds := float64(time.Now().Sub(startTime)) / float64(time.Second)
 	if completionTokens > 0 {
 		return &StreamMetrics{
+			InputTokens:      promptTokens,
 			OutputTokens:     completionTokens,
 			FirstTokenTime:   firstTokenTime,
 			TokensPerSec:     float64(completionTokens) / timeSpentSeconds,
 			TimeSpentSeconds: timeSpentSeconds,
+			Output:           output.String(),
+			Sequences:        sequences,
 		}
 	}
 	// 有些vllm版本的接口不会返回这个统计信息，那就返回手动统计的token数量
@@ -58,6 +90,8 @@ func CalVllmMetrics(stream <-chan []byte, startTime time.Time) *StreamMetrics {
 		FirstTokenTime:   firstTokenTime,
 		TokensPerSec:     float64(count) / timeSpentSeconds,
 		TimeSpentSeconds: timeSpentSeconds,
+		Output:           output.String(),
+		Sequences:        sequences,
 	}
 }
 
@@ -66,6 +100,7 @@ func CalTrtMetrics(stream <-chan []byte, startTime time.Time) *StreamMetrics {
 	var once sync.Once
 	var firstTokenTime float64 // 单位毫秒
 
+	var output strings.Builder
 	completionTokens := -1
 	for data := range stream {
 		once.Do(func() {
@@ -75,6 +110,12 @@ func CalTrtMetrics(stream <-chan []byte, startTime time.Time) *StreamMetrics {
 			continue
 		}
 		completionTokens += 1
+		if matches := dataPattern.FindSubmatch(data); len(matches) == 2 {
+			var chunk postprocess.TrtChunk
+			if json.Unmarshal(matches[1], &chunk) == nil {
+				output.WriteString(chunk.TextOutput)
+			}
+		}
 	}
 	timeSpentSeconds := float64(time.Now().Sub(startTime)) / float64(time.Second)
 	return &StreamMetrics{
@@ -82,23 +123,21 @@ func CalTrtMetrics(stream <-chan []byte, startTime time.Time) *StreamMetrics {
 		FirstTokenTime:   firstTokenTime,
 		TokensPerSec:     float64(completionTokens) / timeSpentSeconds,
 		TimeSpentSeconds: timeSpentSeconds,
+		Output:           output.String(),
+		Sequences:        1,
 	}
 }
 
-// getVllmChatStreamTokens 获取vllm流式对话的token数量
-func getVllmChatStreamTokens(chunk string) (int, int, error) {
+// parseVllmChunk 解析vllm流式输出的chunk
+func parseVllmChunk(chunk string) (*vllmChunk, error) {
 	// 使用正则表达式匹配 "data:" 开头的字符串
-	re := regexp.MustCompile(`^data:\s*(\{.*})`)
-	matches := re.FindStringSubmatch(chunk)
-
+	matches := dataPattern.FindStringSubmatch(chunk)
 	if len(matches) != 2 {
-		return 0, 0, errors.New("invalid input format")
+		return nil, errors.New("invalid input format")
 	}
-
-	jsonData := matches[1]
-	var data openai.ChatCompletionResponse
-	if err := json.Unmarshal([]byte(jsonData), &data); err != nil {
-		return 0, 0, errors.New("invalid input format")
+	var data vllmChunk
+	if err := json.Unmarshal([]byte(matches[1]), &data); err != nil {
+		return nil, errors.New("invalid input format")
 	}
-	return data.Usage.CompletionTokens, data.Usage.PromptTokens, nil
+	return &data, nil
 }
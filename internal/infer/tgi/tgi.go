@@ -6,16 +6,51 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/nullxjx/llm_profiler/config"
 	"github.com/nullxjx/llm_profiler/internal/infer/param"
 	"github.com/nullxjx/llm_profiler/pkg/http"
 )
 
+// SamplingFields TGI 支持的采样参数
+var SamplingFields = []string{"temperature", "topP", "topK", "seed", "repetitionPenalty", "frequencyPenalty", "bestOf"}
+
 type Parameters struct {
-	MaxNewTokens        uint32  `json:"max_new_tokens"`
-	Details             bool    `json:"details"`
-	DecoderInputDetails bool    `json:"decoder_input_details"`
-	Temperature         float32 `json:"temperature"`
-	DoSample            bool    `json:"do_sample"`
+	MaxNewTokens        uint32   `json:"max_new_tokens"`
+	Details             bool     `json:"details"`
+	DecoderInputDetails bool     `json:"decoder_input_details"`
+	Temperature         *float32 `json:"temperature,omitempty"` // TGI 要求温度大于 0，温度为 0 时不发送并关闭采样
+	DoSample            bool     `json:"do_sample"`
+	TopP                *float32 `json:"top_p,omitempty"`
+	TopK                *int     `json:"top_k,omitempty"`
+	Seed                *int     `json:"seed,omitempty"`
+	RepetitionPenalty   *float32 `json:"repetition_penalty,omitempty"`
+	FrequencyPenalty    *float32 `json:"frequency_penalty,omitempty"`
+	BestOf              int      `json:"best_of,omitempty"`
+	AdapterId           string   `json:"adapter_id,omitempty"` // 多 LoRA 部署时使用的 adapter，为空时使用基础模型
+}
+
+// parameters 根据推理参数创建 TGI 的生成参数
+func parameters(c *param.InferConfig) Parameters {
+	s := c.Sampling
+	if s == nil {
+		s = &config.SamplingConfig{}
+	}
+	p := Parameters{
+		MaxNewTokens:        c.MaxTokens,
+		Details:             true,
+		DecoderInputDetails: true,
+		DoSample:            c.Temperature > 0,
+		TopP:                s.TopP,
+		TopK:                s.TopK,
+		Seed:                s.Seed,
+		RepetitionPenalty:   s.RepetitionPenalty,
+		FrequencyPenalty:    s.FrequencyPenalty,
+		BestOf:              s.BestOf,
+	}
+	if c.Temperature > 0 {
+		p.Temperature = &c.Temperature
+	}
+	return p
 }
 
 type InferReq struct {
@@ -37,11 +72,16 @@ type Prefill struct {
 }
 
 type Details struct {
-	FinishReason    string    `json:"finish_reason"`
-	GeneratedTokens int       `json:"generated_tokens"`
-	Seed            uint64    `json:"seed"`
-	Prefill         []Prefill `json:"prefill"`
-	Tokens          []Token   `json:"tokens"`
+	FinishReason    string           `json:"finish_reason"`
+	GeneratedTokens int              `json:"generated_tokens"`
+	Seed            uint64           `json:"seed"`
+	Prefill         []Prefill        `json:"prefill"`
+	Tokens          []Token          `json:"tokens"`
+	BestOfSequences []BestOfSequence `json:"best_of_sequences"` // 设置 best_of 时除返回结果外的其他序列
+}
+
+type BestOfSequence struct {
+	GeneratedTokens int `json:"generated_tokens"`
 }
 
 type InferRsp struct {
@@ -50,20 +90,14 @@ type InferRsp struct {
 }
 
 // InferTGI 调用 TGI 的generate 接口
-func InferTGI(params *param.InferParams, url string) ([]param.InferResult, error) {
+func InferTGI(ctx context.Context, params *param.InferParams, url string) ([]param.InferResult, error) {
 	req := &InferReq{
-		Inputs: params.PromptList[0],
-		Parameters: Parameters{
-			MaxNewTokens:        params.InferConfig.MaxTokens,
-			Details:             true,
-			DecoderInputDetails: true,
-			Temperature:         params.InferConfig.Temperature,
-			DoSample:            true,
-		},
+		Inputs:     params.PromptList[0],
+		Parameters: parameters(params.InferConfig),
 	}
+	req.Parameters.AdapterId = params.Adapter
 	start := time.Now()
 	url = fmt.Sprintf("%s/generate", url)
-	ctx := context.Background()
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, time.Duration(params.Timeout)*time.Millisecond)
 	defer cancel()
 	body, err := http.Post(ctxWithTimeout, url, req)
@@ -74,12 +108,18 @@ func InferTGI(params *param.InferParams, url string) ([]param.InferResult, error
 	if err = json.Unmarshal(body, &rsp); err != nil {
 		return nil, err
 	}
+	// 设置 best_of 时其他序列同样消耗了推理资源，输出token数为所有序列之和
+	outputTokens := len(rsp.Details.Tokens)
+	for _, seq := range rsp.Details.BestOfSequences {
+		outputTokens += seq.GeneratedTokens
+	}
 	return []param.InferResult{
 		{
 			Result:       rsp.GeneratedText,
 			TimeSpent:    time.Now().Sub(start).Milliseconds(),
 			InputTokens:  len(rsp.Details.Prefill),
-			OutputTokens: len(rsp.Details.Tokens),
+			OutputTokens: outputTokens,
+			Sequences:    1 + len(rsp.Details.BestOfSequences),
 		},
 	}, nil
 }
@@ -0,0 +1,35 @@
+package triton
+
+import (
+	"strings"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/infer/param"
+)
+
+// SamplingFields TensorRT-LLM ensemble 模型支持的采样参数
+var SamplingFields = []string{"temperature", "topP", "topK", "seed", "repetitionPenalty", "presencePenalty",
+	"frequencyPenalty", "beamWidth", "minTokens"}
+
+// trtRequest 创建 TensorRT-LLM 的请求，设置采样参数
+func trtRequest(params *param.InferParams, stream bool) *TrtReq {
+	s := params.InferConfig.Sampling
+	if s == nil {
+		s = &config.SamplingConfig{}
+	}
+	return &TrtReq{
+		TextInput:         params.PromptList[0],
+		MaxTokens:         int32(params.InferConfig.MaxTokens),
+		StopWords:         strings.Join(params.InferConfig.StopWords, ","),
+		Stream:            stream,
+		Temperature:       params.InferConfig.Temperature,
+		TopP:              s.TopP,
+		TopK:              s.TopK,
+		RandomSeed:        s.Seed,
+		RepetitionPenalty: s.RepetitionPenalty,
+		PresencePenalty:   s.PresencePenalty,
+		FrequencyPenalty:  s.FrequencyPenalty,
+		BeamWidth:         s.BeamWidth,
+		MinLength:         s.MinTokens,
+	}
+}
@@ -11,13 +11,20 @@ import (
 )
 
 type TrtReq struct {
-	TextInput   string  `json:"text_input"`
-	MaxTokens   int32   `json:"max_tokens"`
-	BadWords    string  `json:"bad_words"`
-	StopWords   string  `json:"stop_words"`
-	Stream      bool    `json:"stream"`
-	TopP        float32 `json:"top_p"`
-	Temperature float32 `json:"temperature"`
+	TextInput         string   `json:"text_input"`
+	MaxTokens         int32    `json:"max_tokens"`
+	BadWords          string   `json:"bad_words"`
+	StopWords         string   `json:"stop_words"`
+	Stream            bool     `json:"stream"`
+	TopP              *float32 `json:"top_p,omitempty"`
+	TopK              *int     `json:"top_k,omitempty"`
+	Temperature       float32  `json:"temperature"`
+	RandomSeed        *int     `json:"random_seed,omitempty"`
+	RepetitionPenalty *float32 `json:"repetition_penalty,omitempty"`
+	PresencePenalty   *float32 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty  *float32 `json:"frequency_penalty,omitempty"`
+	BeamWidth         int      `json:"beam_width,omitempty"`
+	MinLength         int      `json:"min_length,omitempty"`
 }
 
 type TrtRsp struct {
@@ -53,7 +60,7 @@ type ProxyRequest struct {
 	IgnoreEos     bool     `json:"ignore_eos"`
 }
 
-func InferVllmInTriton(p *param.InferParams, url string) ([]param.InferResult, error) {
+func InferVllmInTriton(ctx context.Context, p *param.InferParams, url string) ([]param.InferResult, error) {
 	req := &ProxyRequest{
 		Model:         p.ModelName,
 		Prompt:        p.PromptList[0],
@@ -62,12 +69,11 @@ func InferVllmInTriton(p *param.InferParams, url string) ([]param.InferResult, e
 		MaxTokens:     p.InferConfig.MaxTokens,
 		UseBeamSearch: false,
 		Stream:        false,
-		IgnoreEos:     true,
+		IgnoreEos:     !p.InferConfig.StopAtEos,
 	}
 
 	start := time.Now()
 	url = fmt.Sprintf("%s/v1/completions", url)
-	ctx := context.Background()
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, time.Duration(p.Timeout)*time.Millisecond)
 	defer cancel()
 	body, err := http.Post(ctxWithTimeout, url, req)
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/nullxjx/llm_profiler/internal/infer/param"
@@ -16,18 +15,10 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func InferTrt(params *param.InferParams, url string) ([]param.InferResult, error) {
-	req := &TrtReq{
-		TextInput:   params.PromptList[0],
-		MaxTokens:   int32(params.InferConfig.MaxTokens),
-		StopWords:   strings.Join(params.InferConfig.StopWords, ","),
-		Stream:      false,
-		TopP:        params.InferConfig.TopP,
-		Temperature: params.InferConfig.Temperature,
-	}
+func InferTrt(ctx context.Context, params *param.InferParams, url string) ([]param.InferResult, error) {
+	req := trtRequest(params, false)
 	start := time.Now()
 	url = fmt.Sprintf("%s/v2/models/%s/generate", url, params.ModelName)
-	ctx := context.Background()
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, time.Duration(params.Timeout)*time.Millisecond)
 	defer cancel()
 	body, err := http.Post(ctxWithTimeout, url, req)
@@ -44,17 +35,17 @@ func InferTrt(params *param.InferParams, url string) ([]param.InferResult, error
 		return nil, err
 	}
 
-	var inferResults []param.InferResult
-	for _, r := range res.Choices {
-		// 如果设置beamWidth > 1，对于每条输入，都会有多条输出，这里简单起见，只取第一条输出作为最后的输出
-		inferResults = append(inferResults, param.InferResult{
-			Result:       r.Text,
-			TimeSpent:    time.Now().Sub(start).Milliseconds(),
-			InputTokens:  int(res.Usage.PromptTokens),
-			OutputTokens: int(res.Usage.CompletionTokens),
-		})
+	// 如果设置beamWidth > 1，对于每条输入，都会有多条输出，输出token数为所有序列之和，文本只保留第一条输出
+	inferResult := param.InferResult{
+		TimeSpent:    time.Now().Sub(start).Milliseconds(),
+		InputTokens:  int(res.Usage.PromptTokens),
+		OutputTokens: int(res.Usage.CompletionTokens),
+		Sequences:    len(res.Choices),
+	}
+	if len(res.Choices) > 0 {
+		inferResult.Result = res.Choices[0].Text
 	}
-	return inferResults, nil
+	return []param.InferResult{inferResult}, nil
 }
 
 // StreamInferByTrt trt的流式请求入口
@@ -63,14 +54,7 @@ func StreamInferByTrt(ctx context.Context, url string, params *param.InferParams
 	header := map[string]string{
 		"Content-Type": "application/json",
 	}
-	req := &TrtReq{
-		TextInput:   params.PromptList[0],
-		MaxTokens:   int32(params.InferConfig.MaxTokens),
-		StopWords:   strings.Join(params.InferConfig.StopWords, ","),
-		Stream:      true,
-		TopP:        params.InferConfig.TopP,
-		Temperature: params.InferConfig.Temperature,
-	}
+	req := trtRequest(params, true)
 	// 拼接trt流式url
 	url = fmt.Sprintf("%s/v2/models/%s/generate_stream", url, params.ModelName)
 	rsp, err := http.Stream(ctx, url, header, nil, req)
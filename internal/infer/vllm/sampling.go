@@ -0,0 +1,91 @@
+package vllm
+
+import (
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/infer/param"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// SamplingFields vllm 支持的采样参数
+var SamplingFields = []string{"temperature", "topP", "topK", "seed", "repetitionPenalty", "presencePenalty",
+	"frequencyPenalty", "n", "bestOf", "beamWidth", "minTokens"}
+
+// samplingParams vllm 在 openai 接口之外支持的采样参数
+type samplingParams struct {
+	TopK              *int     `json:"top_k,omitempty"`
+	RepetitionPenalty *float32 `json:"repetition_penalty,omitempty"`
+	MinTokens         int      `json:"min_tokens,omitempty"`
+	UseBeamSearch     bool     `json:"use_beam_search,omitempty"`
+}
+
+// openaiSampling openai 接口中已有的采样参数
+type openaiSampling struct {
+	topP             float32
+	seed             *int
+	presencePenalty  float32
+	frequencyPenalty float32
+	n                int
+	bestOf           int
+}
+
+// sampling 把采样参数转换为 vllm 的请求参数，beam search 时 best_of 为 beam 宽度
+func sampling(c *param.InferConfig) (openaiSampling, samplingParams) {
+	s := c.Sampling
+	if s == nil {
+		s = &config.SamplingConfig{}
+	}
+	o := openaiSampling{
+		topP:             value(s.TopP),
+		seed:             s.Seed,
+		presencePenalty:  value(s.PresencePenalty),
+		frequencyPenalty: value(s.FrequencyPenalty),
+		n:                s.GetN(),
+		bestOf:           s.BestOf,
+	}
+	p := samplingParams{
+		TopK:              s.TopK,
+		RepetitionPenalty: s.RepetitionPenalty,
+		MinTokens:         s.MinTokens,
+	}
+	if s.BeamWidth > 1 {
+		p.UseBeamSearch = true
+		o.bestOf = s.BeamWidth
+	}
+	return o, p
+}
+
+// completionRequest 创建补全请求，设置采样参数
+func completionRequest(params *param.InferParams, stream bool) *CompletionReq {
+	o, p := sampling(params.InferConfig)
+	req := &CompletionReq{
+		CompletionRequest: openai.CompletionRequest{
+			Model:            params.ModelName,
+			Prompt:           params.PromptList[0],
+			Stop:             params.InferConfig.StopWords,
+			MaxTokens:        int(params.InferConfig.MaxTokens),
+			TopP:             o.topP,
+			Seed:             o.seed,
+			PresencePenalty:  o.presencePenalty,
+			FrequencyPenalty: o.frequencyPenalty,
+			N:                o.n,
+			BestOf:           o.bestOf,
+			Stream:           stream,
+		},
+		Temperature:    &params.InferConfig.Temperature,
+		samplingParams: p,
+		IgnoreEos:      !params.InferConfig.StopAtEos,
+	}
+	if stream {
+		req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	}
+	return req
+}
+
+func value[T any](p *T) T {
+	var v T
+	if p != nil {
+		v = *p
+	}
+	return v
+}
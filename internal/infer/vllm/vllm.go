@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/nullxjx/llm_profiler/config"
 	"github.com/nullxjx/llm_profiler/internal/infer/param"
 	"github.com/nullxjx/llm_profiler/internal/infer/stream/postprocess"
 	"github.com/nullxjx/llm_profiler/internal/infer/type/stream"
+	"github.com/nullxjx/llm_profiler/internal/prompt"
 	"github.com/nullxjx/llm_profiler/pkg/http"
 
 	"github.com/pkg/errors"
@@ -20,26 +22,25 @@ import (
 type CompletionReq struct {
 	openai.CompletionRequest
 	*openai.StreamOptions `json:"stream_options,omitempty"`
-	IgnoreEos             bool `json:"ignore_eos"`
+	Temperature           *float32 `json:"temperature,omitempty"` // 覆盖 openai 中的 temperature，温度为 0 时也会发送
+	samplingParams
+	IgnoreEos bool `json:"ignore_eos"`
+}
+
+// ChatCompletionReq vllm 对话请求参数
+type ChatCompletionReq struct {
+	openai.ChatCompletionRequest
+	Temperature *float32 `json:"temperature,omitempty"` // 覆盖 openai 中的 temperature，温度为 0 时也会发送
+	BestOf      int      `json:"best_of,omitempty"`
+	samplingParams
+	IgnoreEos bool `json:"ignore_eos"`
 }
 
 // Completion 调用 vLLM 的/v1/completions接口
-func Completion(params *param.InferParams, url string) (*param.InferRsp, error) {
-	req := &CompletionReq{
-		CompletionRequest: openai.CompletionRequest{
-			Model:       params.ModelName,
-			Prompt:      params.PromptList[0],
-			Stop:        params.InferConfig.StopWords,
-			Temperature: params.InferConfig.Temperature,
-			MaxTokens:   int(params.InferConfig.MaxTokens),
-			N:           1,
-			Stream:      false,
-		},
-		IgnoreEos: true,
-	}
+func Completion(ctx context.Context, params *param.InferParams, url string) (*param.InferRsp, error) {
+	req := completionRequest(params, false)
 
 	url = fmt.Sprintf("%s/v1/completions", url)
-	ctx := context.Background()
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, time.Duration(params.Timeout)*time.Millisecond)
 	defer cancel()
 	body, err := http.Post(ctxWithTimeout, url, req)
@@ -54,25 +55,25 @@ func Completion(params *param.InferParams, url string) (*param.InferRsp, error)
 }
 
 // CompletionByVLLM 调用 vLLM 的/v1/completions接口，并返回统计信息
-func CompletionByVLLM(params *param.InferParams, serviceURL string) ([]param.InferResult, error) {
+func CompletionByVLLM(ctx context.Context, params *param.InferParams, serviceURL string) (
+	[]param.InferResult, error) {
 	start := time.Now()
-	result, err := Completion(params, serviceURL)
+	result, err := Completion(ctx, params, serviceURL)
 	if err != nil {
 		return nil, err
 	}
 
-	// 对推理结果进行提取
-	var inferResults []param.InferResult
-	for _, choice := range result.Choices {
-		inferResults = append(inferResults, param.InferResult{
-			Result:       choice.Text,
-			TimeSpent:    time.Now().Sub(start).Milliseconds(),
-			InputTokens:  int(result.Usage.PromptTokens),
-			OutputTokens: int(result.Usage.CompletionTokens),
-		})
+	// n > 1 或 beam search 时返回多个序列，usage 中的输出token数为所有序列之和，文本只保留第一个序列
+	inferResult := param.InferResult{
+		TimeSpent:    time.Now().Sub(start).Milliseconds(),
+		InputTokens:  int(result.Usage.PromptTokens),
+		OutputTokens: int(result.Usage.CompletionTokens),
+		Sequences:    len(result.Choices),
 	}
-
-	return inferResults, nil
+	if len(result.Choices) > 0 {
+		inferResult.Result = result.Choices[0].Text
+	}
+	return []param.InferResult{inferResult}, nil
 }
 
 // StreamCompletionByVLLM vLLM的流式补全请求入口
@@ -81,21 +82,7 @@ func StreamCompletionByVLLM(ctx context.Context, url string, params *param.Infer
 	header := map[string]string{
 		"Content-Type": "application/json",
 	}
-	req := &CompletionReq{
-		CompletionRequest: openai.CompletionRequest{
-			Model:       params.ModelName,
-			Prompt:      params.PromptList[0],
-			Stop:        params.InferConfig.StopWords,
-			Temperature: params.InferConfig.Temperature,
-			MaxTokens:   int(params.InferConfig.MaxTokens),
-			N:           1,
-			Stream:      true,
-		},
-		StreamOptions: &openai.StreamOptions{
-			IncludeUsage: true,
-		},
-		IgnoreEos: true,
-	}
+	req := completionRequest(params, true)
 	// 拼接vllm流式url
 	url = fmt.Sprintf("%s/v1/completions", url)
 	rsp, err := http.Stream(ctx, url, header, nil, req)
@@ -121,27 +108,38 @@ func StreamChatByVLLM(ctx context.Context, url string, params *param.InferParams
 	header := map[string]string{
 		"Content-Type": "application/json",
 	}
-	var msgs []openai.ChatCompletionMessage
-	msgs = append(msgs, openai.ChatCompletionMessage{
-		Role:    "system",
-		Content: "You are a helpful assistant.",
-	})
-	msgs = append(msgs, openai.ChatCompletionMessage{
-		Role:    "user",
-		Content: params.PromptList[0],
-	})
-	req := &openai.ChatCompletionRequest{
-		Model:       params.ModelName,
-		Messages:    msgs,
-		MaxTokens:   int(params.InferConfig.MaxTokens),
-		Temperature: params.InferConfig.Temperature,
-		TopP:        params.InferConfig.TopP,
-		N:           1,
-		Stream:      true,
-		Stop:        params.InferConfig.StopWords,
-		StreamOptions: &openai.StreamOptions{
-			IncludeUsage: true,
+	messages := params.Messages
+	if len(messages) == 0 {
+		messages = []prompt.Message{
+			{Role: prompt.RoleSystem, Content: config.DefaultSystemPrompt},
+			{Role: prompt.RoleUser, Content: params.PromptList[0]},
+		}
+	}
+	msgs := make([]openai.ChatCompletionMessage, 0, len(messages))
+	for _, m := range messages {
+		msgs = append(msgs, openai.ChatCompletionMessage{Role: m.Role, Content: m.Content})
+	}
+	o, p := sampling(params.InferConfig)
+	req := &ChatCompletionReq{
+		ChatCompletionRequest: openai.ChatCompletionRequest{
+			Model:            params.ModelName,
+			Messages:         msgs,
+			MaxTokens:        int(params.InferConfig.MaxTokens),
+			TopP:             o.topP,
+			Seed:             o.seed,
+			PresencePenalty:  o.presencePenalty,
+			FrequencyPenalty: o.frequencyPenalty,
+			N:                o.n,
+			Stream:           true,
+			Stop:             params.InferConfig.StopWords,
+			StreamOptions: &openai.StreamOptions{
+				IncludeUsage: true,
+			},
 		},
+		Temperature:    &params.InferConfig.Temperature,
+		BestOf:         o.bestOf,
+		samplingParams: p,
+		IgnoreEos:      !params.InferConfig.StopAtEos,
 	}
 	// 拼接vllm流式url
 	url = fmt.Sprintf("%s/v1/chat/completions", url)
@@ -1,29 +1,33 @@
 package speed
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/nullxjx/llm_profiler/config"
 	"github.com/nullxjx/llm_profiler/internal/infer/param"
 	"github.com/nullxjx/llm_profiler/internal/infer/tgi"
 	"github.com/nullxjx/llm_profiler/internal/infer/triton"
 	"github.com/nullxjx/llm_profiler/internal/infer/type/backend"
 	"github.com/nullxjx/llm_profiler/internal/infer/vllm"
+	"github.com/nullxjx/llm_profiler/internal/prompt"
 	"github.com/nullxjx/llm_profiler/internal/utils"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// SpeedTest 单条速度测试
-func SpeedTest(ip, modelName, backend string, port, promptLength int, temperature float32) {
+// SpeedTest 单条速度测试，tokenizerPath 不为空时随机生成恰好 promptLength 个token的 prompt
+func SpeedTest(ip, modelName, backend string, port, promptLength int, temperature float32, tokenizerPath string) {
 	log.Infof("Single request speed test on model %v at %v:%v", modelName, ip, port)
 	var speedValues []float64
-	prompt := "The meaning of life is"
+	text := "The meaning of life is"
 	tokens := 5
 	if promptLength > 0 {
-		inputs, err := utils.ReadPromptsWithTokens(promptLength)
+		inputs, err := loadPrompts(promptLength, tokenizerPath)
 		if err != nil {
 			log.Errorf("read inputs error: %v", err)
 			return
@@ -31,11 +35,14 @@ func SpeedTest(ip, modelName, backend string, port, promptLength int, temperatur
 		// 随机选择一个值
 		rand.Seed(time.Now().UnixNano())      // 设置随机数种子
 		randomIndex := rand.Intn(len(inputs)) // 生成一个随机索引
-		prompt = inputs[randomIndex].Prompt
+		text = inputs[randomIndex].Text
 		tokens = inputs[randomIndex].Tokens
+		if tokens == 0 {
+			tokens = promptLength
+		}
 		log.Debugf("prompt index: %v", randomIndex)
 	}
-	log.Infof("prompt string len: %d, estimated tokens: %d", len(prompt), tokens)
+	log.Infof("prompt string len: %d, estimated tokens: %d", len(text), tokens)
 	for m := 32; m <= 256; m += 32 {
 		var successCnt = 0
 		var totalTime float64 = 0
@@ -43,7 +50,7 @@ func SpeedTest(ip, modelName, backend string, port, promptLength int, temperatur
 		for i := 0; i < 10; i++ {
 			start := time.Now()
 			req := &param.InferParams{
-				PromptList:   []string{prompt},
+				PromptList:   []string{text},
 				ModelName:    modelName,
 				ModelVersion: "1",
 				Timeout:      100000,
@@ -51,8 +58,6 @@ func SpeedTest(ip, modelName, backend string, port, promptLength int, temperatur
 					StopWords:   []string{},
 					MaxTokens:   uint32(m),
 					Temperature: temperature,
-					BeamWidth:   1,
-					TopP:        1,
 				},
 			}
 			outputTokens := sendRequest(ip, backend, port, req)
@@ -72,8 +77,21 @@ func SpeedTest(ip, modelName, backend string, port, promptLength int, temperatur
 	log.Infof("speed for single request: %.1f tokens/s", utils.MeanWithoutMinMax(speedValues))
 }
 
+// loadPrompts 读取给定长度的 prompt，数据集中没有该长度或设置了分词器时随机生成
+func loadPrompts(promptLength int, tokenizerPath string) ([]*prompt.Prompt, error) {
+	cfg := &config.Config{
+		InputTokens: promptLength,
+		Prompt:      config.PromptConfig{Tokenizer: tokenizerPath, Count: 1},
+	}
+	if _, err := os.Stat(cfg.Prompt.BucketPath(promptLength)); err != nil || tokenizerPath != "" {
+		log.Infof("Generate synthetic prompt with %d tokens", promptLength)
+		cfg.Prompt.Source = config.PromptSourceSynthetic
+	}
+	return prompt.Load(cfg)
+}
+
 func sendRequest(ip, back string, port int, req *param.InferParams) int {
-	metricHandlers := map[string]func(*param.InferParams, string) ([]param.InferResult, error){
+	metricHandlers := map[string]func(context.Context, *param.InferParams, string) ([]param.InferResult, error){
 		string(backend.VLLM): vllm.CompletionByVLLM,
 		string(backend.TRT):  triton.InferTrt,
 		string(backend.TGI):  tgi.InferTGI,
@@ -82,7 +100,7 @@ func sendRequest(ip, back string, port int, req *param.InferParams) int {
 	if !ok {
 		panic(fmt.Sprintf("unsupported backend: %s", back))
 	}
-	res, err := handler(req, fmt.Sprintf("%s:%d", ip, port))
+	res, err := handler(context.Background(), req, config.GetUrl(&config.Config{ServerIp: ip, Port: port}))
 	if err != nil || len(res) == 0 {
 		log.Errorf("send %v request error: %v", back, err)
 		return 0
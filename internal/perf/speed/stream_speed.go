@@ -12,6 +12,7 @@ import (
 	"github.com/nullxjx/llm_profiler/internal/infer/triton"
 	"github.com/nullxjx/llm_profiler/internal/infer/type/backend"
 	"github.com/nullxjx/llm_profiler/internal/infer/vllm"
+	"github.com/nullxjx/llm_profiler/internal/prompt"
 	"github.com/nullxjx/llm_profiler/internal/utils"
 
 	log "github.com/sirupsen/logrus"
@@ -24,17 +25,23 @@ type StreamSpeed struct {
 }
 
 // CalStreamSpeed 计算流式场景下的相关指标
-func CalStreamSpeed(cfg *config.Config) (*StreamSpeed, error) {
-	prompts, err := utils.ReadPrompts(cfg.InputTokens)
+func CalStreamSpeed(ctx context.Context, cfg *config.Config) (*StreamSpeed, error) {
+	prompts, err := prompt.Load(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("read inputs error: %v", err)
 	}
 	// 从prompts中选前20条进行测试，去掉最小最大值后取均值
+	if len(prompts) > 20 {
+		prompts = prompts[:20]
+	}
 	speedList := make([]float64, 0)
 	firstTokenTimeList := make([]float64, 0)
-	for _, prompt := range prompts[:20] {
+	for _, p := range prompts {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		start := time.Now()
-		s, err := sendStreamRequest(cfg, prompt)
+		s, err := sendStreamRequest(ctx, cfg, p)
 		if err != nil {
 			continue
 		}
@@ -75,7 +82,7 @@ func countOutputTokens(cfg *config.Config, s <-chan []byte, startTime time.Time)
 	return handler(s, startTime)
 }
 
-func sendStreamRequest(cfg *config.Config, prompt string) (<-chan []byte, error) {
+func sendStreamRequest(ctx context.Context, cfg *config.Config, p *prompt.Prompt) (<-chan []byte, error) {
 	backendHandlers := map[string]func(ctx context.Context, url string, params *param.InferParams) (
 		<-chan []byte, error){
 		string(backend.VLLM): vllm.StreamChatByVLLM,
@@ -85,15 +92,17 @@ func sendStreamRequest(cfg *config.Config, prompt string) (<-chan []byte, error)
 	if !ok {
 		panic(fmt.Sprintf("unsupported backend: %s", cfg.Backend))
 	}
-	return handler(context.Background(), config.GetUrl(cfg),
+	return handler(ctx, config.GetUrl(cfg),
 		&param.InferParams{
-			PromptList:   []string{prompt},
+			PromptList:   []string{p.Text},
+			Messages:     p.Messages,
 			ModelName:    cfg.Model.Name,
 			ModelVersion: cfg.Model.Version,
 			InferConfig: &param.InferConfig{
 				StopWords:   cfg.StopWords,
 				MaxTokens:   cfg.MaxTokens,
 				Temperature: cfg.Temperature,
+				Sampling:    &cfg.Sampling,
 			},
 		})
 }
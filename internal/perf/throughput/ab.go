@@ -0,0 +1,200 @@
+package throughput
+
+import (
+	"sync"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/balancer"
+	"github.com/nullxjx/llm_profiler/internal/infer/param"
+	"github.com/nullxjx/llm_profiler/internal/utils"
+
+	"github.com/montanaflynn/stats"
+	log "github.com/sirupsen/logrus"
+)
+
+// A/B 对比测试中结果所属的服务
+const (
+	sideBaseline = iota
+	sideCandidate
+)
+
+// candidate A/B 对比测试中的对比服务
+type candidate struct {
+	cfg      *config.Config
+	bal      *balancer.Balancer
+	baseline string // 基线服务的名称
+	name     string // 对比服务的名称
+	ownModel bool   // 对比服务是否设置了自己的模型
+}
+
+// newCandidate 根据配置创建对比服务，没有开启 A/B 对比测试时返回 nil
+func newCandidate(cfg *config.Config) (*candidate, error) {
+	if !cfg.AB.Enabled() {
+		return nil, nil
+	}
+	c := &candidate{cfg: cfg.Candidate(), baseline: cfg.AB.GetName(), name: cfg.AB.Candidate.GetName(),
+		ownModel: cfg.AB.Candidate.Model.Name != ""}
+	bal, err := balancer.New(c.cfg)
+	if err != nil {
+		return nil, err
+	}
+	c.bal = bal
+	return c, nil
+}
+
+// candidateRound 对比服务一轮的请求和结果
+type candidateRound struct {
+	*candidate
+	results chan param.Result
+	counter *param.Counter
+	rec     *recorder
+}
+
+// start 开始一轮测试，对比服务的结果保存在 name 加 _candidate 后缀的文件中
+func (c *candidate) start(name string, concurrency int, pairs *pairCollector) *candidateRound {
+	r := &candidateRound{
+		candidate: c,
+		results:   make(chan param.Result, concurrency),
+		counter:   &param.Counter{},
+		rec:       newRecorder(c.cfg, name+"_candidate.jsonl"),
+	}
+	r.rec.pairs, r.rec.side = pairs, sideCandidate
+	if c.bal != nil {
+		c.bal.Reset()
+	}
+	go r.rec.run(r.results)
+	return r
+}
+
+// send 在基线请求发送的同一时刻把同一条请求发送到对比服务
+func (r *candidateRound) send(base *param.RequestParam) {
+	req := *base
+	req.Config, req.Balancer = r.cfg, r.bal
+	req.Result, req.Counter = r.results, r.counter
+	req.Candidate = true
+	if r.ownModel {
+		// 对比服务设置了自己的模型时不使用基线的模型混合
+		req.Model = ""
+	}
+	base.Wg.Add(1)
+	go sendRequest(&req)
+}
+
+// finish 在所有请求结束后调用，等待对比服务的结果处理完
+func (r *candidateRound) finish() {
+	close(r.results)
+	r.rec.wait()
+}
+
+// compare 统计对比服务本轮的指标，与基线的统计结果 base 按请求配对比较，p 为基线本轮的统计参数
+func (r *candidateRound) compare(base *StatisticsSummary, p StatisticsParam, pairs *pairCollector) {
+	p.Accumulator, p.Balancer = r.rec.acc, r.bal
+	p.TotalCount, p.SuccessCount = r.counter.Total, r.counter.Success
+	p.FailedCount, p.CanceledCount = r.counter.Failed, r.counter.Canceled
+	cand := calMetrics(&p)
+	ab := &ABSummary{
+		Baseline:             r.baseline,
+		Candidate:            r.name,
+		CandidateStatistics:  cand,
+		ThroughputGain:       -reduction(cand.ServerOutputTokensPerSecond, base.ServerOutputTokensPerSecond),
+		RequestPerSecondGain: -reduction(cand.RequestPerSecond, base.RequestPerSecond),
+	}
+	pairs.summarize(ab, r.cfg.Stream)
+	base.AB = ab
+	logAB(ab, base)
+}
+
+// pairSample 一条成功请求中用于配对比较的指标
+type pairSample struct {
+	timeSpent       float64
+	firstTokenTime  float64
+	tokensPerSecond float64
+}
+
+// pairCollector 收集 A/B 对比测试中两个服务的成功结果，按配对编号对齐，两个服务的结果并发写入
+type pairCollector struct {
+	mu      sync.Mutex
+	samples [2]map[int]pairSample
+}
+
+func newPairCollector() *pairCollector {
+	return &pairCollector{samples: [2]map[int]pairSample{make(map[int]pairSample), make(map[int]pairSample)}}
+}
+
+func (p *pairCollector) add(side int, result *param.Result) {
+	if p == nil || result.Pair == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.samples[side][result.Pair] = pairSample{
+		timeSpent:       float64(result.TimeSpent),
+		firstTokenTime:  result.FirstTokenTime,
+		tokensPerSecond: result.TokensPerSecond,
+	}
+}
+
+// summarize 计算两个服务都成功的请求对的差值，首token时间和客户端速度仅在流式场景下比较
+func (p *pairCollector) summarize(ab *ABSummary, stream bool) {
+	var baseTime, candTime, baseFirst, candFirst, baseSpeed, candSpeed []float64
+	faster := 0
+	for pair, b := range p.samples[sideBaseline] {
+		c, ok := p.samples[sideCandidate][pair]
+		if !ok {
+			continue
+		}
+		baseTime, candTime = append(baseTime, b.timeSpent), append(candTime, c.timeSpent)
+		baseFirst, candFirst = append(baseFirst, b.firstTokenTime), append(candFirst, c.firstTokenTime)
+		baseSpeed, candSpeed = append(baseSpeed, b.tokensPerSecond), append(candSpeed, c.tokensPerSecond)
+		if c.timeSpent < b.timeSpent {
+			faster++
+		}
+	}
+	ab.Pairs = len(baseTime)
+	if ab.Pairs == 0 {
+		return
+	}
+	ab.CandidateFaster = float64(faster) / float64(ab.Pairs) * 100
+	ab.Latency = pairedDiff(baseTime, candTime)
+	if stream {
+		ab.FirstTokenTime = pairedDiff(baseFirst, candFirst)
+		ab.ClientOutputTokensPerSecond = pairedDiff(baseSpeed, candSpeed)
+	}
+}
+
+// pairedDiff 计算配对样本 candidate - base 的差值分布
+func pairedDiff(base, cand []float64) *PairedDiff {
+	diffs := make(stats.Float64Data, len(base))
+	for i := range base {
+		diffs[i] = cand[i] - base[i]
+	}
+	mean, _ := diffs.Mean()
+	p50, _ := diffs.Percentile(50)
+	p90, _ := diffs.Percentile(90)
+	baseMean, _ := stats.Mean(base)
+	d := &PairedDiff{Mean: mean, P50: p50, P90: p90, PValue: utils.PairedTTest(diffs)}
+	if baseMean != 0 {
+		d.Relative = mean / baseMean * 100
+	}
+	return d
+}
+
+// logAB 输出 A/B 对比测试的结果，差值均为 candidate 减去基线
+func logAB(ab *ABSummary, base *StatisticsSummary) {
+	cand := ab.CandidateStatistics
+	log.Infof("  ab %v vs %v: [pairs: %v, success: %v/%v, fail: %v/%v] | Server: %.1f -> %.1f tokens/s (%+.1f%%), "+
+		"%.2f -> %.2f req/s (%+.1f%%)", ab.Baseline, ab.Candidate, ab.Pairs, base.Success, cand.Success,
+		base.Fail, cand.Fail, base.ServerOutputTokensPerSecond, cand.ServerOutputTokensPerSecond, ab.ThroughputGain,
+		base.RequestPerSecond, cand.RequestPerSecond, ab.RequestPerSecondGain)
+	if d := ab.Latency; d != nil {
+		log.Infof("  ab latency diff: mean %+.1f ms (%+.1f%%), P50 %+.1f ms, P90 %+.1f ms, p-value %.3g "+
+			"| %v faster in %.0f%% pairs", d.Mean, d.Relative, d.P50, d.P90, d.PValue, ab.Candidate, ab.CandidateFaster)
+	}
+	if d := ab.FirstTokenTime; d != nil {
+		log.Infof("  ab first token diff: mean %+.1f ms (%+.1f%%), P50 %+.1f ms, P90 %+.1f ms, p-value %.3g",
+			d.Mean, d.Relative, d.P50, d.P90, d.PValue)
+	}
+	if d := ab.ClientOutputTokensPerSecond; d != nil {
+		log.Infof("  ab client speed diff: mean %+.1f tokens/s (%+.1f%%), p-value %.3g", d.Mean, d.Relative, d.PValue)
+	}
+}
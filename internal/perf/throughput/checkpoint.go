@@ -0,0 +1,108 @@
+package throughput
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nullxjx/llm_profiler/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const checkpointFile = "checkpoint.json"
+
+// Checkpoint 每轮结束后保存的断点信息，用于中断或崩溃后从下一轮继续测试
+type Checkpoint struct {
+	ConfigHash      string               `json:"config_hash"`      // 测试配置的哈希，恢复时用于校验配置未被修改
+	MaxStreamSpeed  float64              `json:"max_stream_speed"` // 已测得的最大流式速度，恢复时无需重新测试
+	NextConcurrency int                  `json:"next_concurrency"` // 下一轮要测试的并发度
+	Stopped         bool                 `json:"stopped"`          // 是否已经满足停止条件，测试已完成
+	Rounds          []*StatisticsSummary `json:"rounds"`           // 已完成轮次的统计结果，也是停止判断依赖的历史数据
+}
+
+// NewCheckpoint 根据配置创建新的断点信息并立即保存到 saveDir，需在配置被运行时修改之前、
+// saveDir 中写入其他文件之前调用，保证第一轮或测速阶段崩溃后也能使用 --resume 继续
+func NewCheckpoint(cfg *config.Config) (*Checkpoint, error) {
+	hash, err := config.Hash(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cp := &Checkpoint{
+		ConfigHash:      hash,
+		NextConcurrency: cfg.StartConcurrency,
+	}
+	if err = cp.save(cfg.SaveDir); err != nil {
+		return nil, fmt.Errorf("save checkpoint error: %v", err)
+	}
+	return cp, nil
+}
+
+// LoadCheckpoint 从 saveDir 中读取断点信息，并校验配置是否与断点一致
+func LoadCheckpoint(cfg *config.Config) (*Checkpoint, error) {
+	cp, err := ReadCheckpoint(cfg.SaveDir)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := config.Hash(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if hash != cp.ConfigHash {
+		return nil, fmt.Errorf("config has changed since checkpoint, hash %s != %s", hash, cp.ConfigHash)
+	}
+	return cp, nil
+}
+
+// ReadCheckpoint 从 saveDir 中读取断点信息，可用于查看测试进度
+func ReadCheckpoint(saveDir string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filepath.Join(saveDir, checkpointFile))
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint error: %v", err)
+	}
+	var cp Checkpoint
+	if err = json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("parse checkpoint error: %v", err)
+	}
+	return &cp, nil
+}
+
+// restore 把断点中已完成轮次的结果恢复到统计缓存中
+func (cp *Checkpoint) restore() {
+	for _, round := range cp.Rounds {
+		statistics[round.Concurrency] = round
+	}
+}
+
+// update 根据当前统计结果更新断点并保存，不完整的轮次不会被记录
+func (cp *Checkpoint) update(cfg *config.Config, next int, stopped bool) {
+	cp.MaxStreamSpeed = cfg.MaxStreamSpeed
+	cp.NextConcurrency = next
+	cp.Stopped = stopped
+	cp.Rounds = cp.Rounds[:0]
+	for _, s := range sortedStatistics() {
+		if !s.Partial {
+			cp.Rounds = append(cp.Rounds, s)
+		}
+	}
+	if err := cp.save(cfg.SaveDir); err != nil {
+		log.Errorf("save checkpoint error: %v", err)
+	}
+}
+
+// save 先写临时文件再重命名，避免进程崩溃时留下损坏的断点文件
+func (cp *Checkpoint) save(saveDir string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(saveDir, os.ModePerm); err != nil {
+		return err
+	}
+	path := filepath.Join(saveDir, checkpointFile)
+	if err = os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,209 @@
+package throughput
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nullxjx/llm_profiler/internal/infer/param"
+
+	"github.com/montanaflynn/stats"
+)
+
+const (
+	dashboardRefresh = time.Second      // 面板刷新间隔
+	rollingWindow    = 30 * time.Second // 滚动吞吐量和延迟分位数的统计窗口
+	sparklineWidth   = 60               // 折线图展示最近多少秒
+	maxPreviousRound = 5                // 展示最近多少轮的统计结果
+)
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// dashboard 在终端中实时展示当前轮次的进度，数据来自 step 使用的同一个结果 channel
+type dashboard struct {
+	mu          sync.Mutex
+	out         io.Writer
+	concurrency int
+	roundStart  time.Time
+	counter     *param.Counter
+	sent        atomic.Int32
+	previous    []*StatisticsSummary
+	window      []windowSample
+	ttft        []secondBucket // 每秒的平均首token时间
+	speed       []secondBucket // 每秒的平均客户端输出速度
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+type windowSample struct {
+	at           time.Time
+	timeSpent    float64
+	outputTokens int
+}
+
+type secondBucket struct {
+	second int64
+	sum    float64
+	count  int
+}
+
+func newDashboard(out io.Writer, concurrency int, counter *param.Counter, previous []*StatisticsSummary) *dashboard {
+	if len(previous) > maxPreviousRound {
+		previous = previous[len(previous)-maxPreviousRound:]
+	}
+	return &dashboard{
+		out:         out,
+		concurrency: concurrency,
+		roundStart:  time.Now(),
+		counter:     counter,
+		previous:    previous,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// start 定时刷新面板，直到 close 被调用
+func (d *dashboard) start() {
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(dashboardRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stop:
+				d.render()
+				return
+			case <-ticker.C:
+				d.render()
+			}
+		}
+	}()
+}
+
+// close 停止刷新，并输出本轮最后的状态
+func (d *dashboard) close() {
+	close(d.stop)
+	<-d.done
+}
+
+// dispatched 记录一条请求被发出，未开启面板时 d 为 nil
+func (d *dashboard) dispatched() {
+	if d == nil {
+		return
+	}
+	d.sent.Add(1)
+}
+
+// observe 记录一条成功请求的结果，未开启面板时 d 为 nil
+func (d *dashboard) observe(result *param.Result) {
+	if d == nil {
+		return
+	}
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.window = append(d.window, windowSample{now, float64(result.TimeSpent), result.OutputTokens})
+	if result.FirstTokenTime > 0 {
+		d.ttft = addToBucket(d.ttft, now.Unix(), result.FirstTokenTime)
+	}
+	if result.TokensPerSecond > 0 {
+		d.speed = addToBucket(d.speed, now.Unix(), result.TokensPerSecond)
+	}
+}
+
+func addToBucket(buckets []secondBucket, second int64, v float64) []secondBucket {
+	if n := len(buckets); n > 0 && buckets[n-1].second == second {
+		buckets[n-1].sum += v
+		buckets[n-1].count++
+		return buckets
+	}
+	buckets = append(buckets, secondBucket{second: second, sum: v, count: 1})
+	if len(buckets) > sparklineWidth {
+		buckets = buckets[len(buckets)-sparklineWidth:]
+	}
+	return buckets
+}
+
+func (d *dashboard) render() {
+	now := time.Now()
+	d.mu.Lock()
+	// 丢弃滚动窗口之外的数据
+	i := 0
+	for i < len(d.window) && now.Sub(d.window[i].at) > rollingWindow {
+		i++
+	}
+	d.window = d.window[i:]
+	var latencies stats.Float64Data
+	tokens := 0
+	for _, s := range d.window {
+		latencies = append(latencies, s.timeSpent)
+		tokens += s.outputTokens
+	}
+	ttftLine, speedLine := sparkline(d.ttft), sparkline(d.speed)
+	d.mu.Unlock()
+
+	windowSeconds := rollingWindow.Seconds()
+	if elapsed := now.Sub(d.roundStart).Seconds(); elapsed < windowSeconds {
+		windowSeconds = elapsed
+	}
+	success := atomic.LoadInt32(&d.counter.Success)
+	failed := atomic.LoadInt32(&d.counter.Failed)
+	canceled := atomic.LoadInt32(&d.counter.Canceled)
+	total := atomic.LoadInt32(&d.counter.Total) // 多轮对话负载中一次发送对应多条请求
+	sent := d.sent.Load()
+	p50, _ := stats.Percentile(latencies, 50)
+	p90, _ := stats.Percentile(latencies, 90)
+	p99, _ := stats.Percentile(latencies, 99)
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J") // 光标移到左上角并清屏
+	fmt.Fprintf(&b, "LLM-Profiler  round: concurrency %d  elapsed: %s\n\n",
+		d.concurrency, now.Sub(d.roundStart).Truncate(time.Second))
+	fmt.Fprintf(&b, "sent: %d/%d  in-flight: %d  success: %d  fail: %d  canceled: %d\n",
+		sent, d.concurrency, total-success-failed-canceled, success, failed, canceled)
+	fmt.Fprintf(&b, "last %.0fs: %.1f tokens/s  %.2f req/s  latency P50/P90/P99: %.0f/%.0f/%.0f ms\n\n",
+		windowSeconds, float64(tokens)/windowSeconds, float64(len(latencies))/windowSeconds, p50, p90, p99)
+	if ttftLine != "" {
+		fmt.Fprintf(&b, "first token ms   %s\n", ttftLine)
+	}
+	if speedLine != "" {
+		fmt.Fprintf(&b, "client tokens/s  %s\n", speedLine)
+	}
+	if len(d.previous) > 0 {
+		b.WriteString("\nprevious rounds:\n")
+		fmt.Fprintf(&b, "%12s %8s %8s %12s %10s %10s %12s\n",
+			"concurrency", "success", "fail", "tokens/s", "req/s", "P90 ms", "first token")
+		for _, s := range d.previous {
+			fmt.Fprintf(&b, "%12d %8d %8d %12.1f %10.2f %10.0f %12.1f\n", s.Concurrency, s.Success, s.Fail,
+				s.ServerOutputTokensPerSecond, s.RequestPerSecond, s.P90, s.FirstTokenTime)
+		}
+	}
+	b.WriteString("\nPress Ctrl-C to stop and save partial results, logs are written to test.log\n")
+	_, _ = io.WriteString(d.out, b.String())
+}
+
+// sparkline 把每秒的均值画成折线图，两端标注最小最大值
+func sparkline(buckets []secondBucket) string {
+	if len(buckets) == 0 {
+		return ""
+	}
+	values := make([]float64, len(buckets))
+	minVal, maxVal := buckets[0].sum/float64(buckets[0].count), 0.0
+	for i, bucket := range buckets {
+		values[i] = bucket.sum / float64(bucket.count)
+		minVal = min(minVal, values[i])
+		maxVal = max(maxVal, values[i])
+	}
+	var b strings.Builder
+	for _, v := range values {
+		idx := 0
+		if maxVal > minVal {
+			idx = int((v - minVal) / (maxVal - minVal) * float64(len(sparkBlocks)-1))
+		}
+		b.WriteRune(sparkBlocks[idx])
+	}
+	return fmt.Sprintf("%8.1f %s %.1f", minVal, b.String(), maxVal)
+}
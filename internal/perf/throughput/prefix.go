@@ -0,0 +1,59 @@
+package throughput
+
+import (
+	"context"
+	"time"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/balancer"
+	"github.com/nullxjx/llm_profiler/internal/workload"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// controlStep 使用前缀各不相同的 prompt 进行一轮对照测试，返回该轮的统计结果，对照轮次不计入测试结果
+func controlStep(ctx context.Context, cfg *config.Config, wl *workload.Workload, bal *balancer.Balancer,
+	cand *candidate, concurrency int) *StatisticsSummary {
+	log.Infof("🙏🙏🙏 start control round without shared prefix at concurrency %v", concurrency)
+	wl.SetControl(true)
+	step(ctx, cfg, wl, nil, bal, cand, concurrency)
+	wl.SetControl(false)
+	control := statistics[concurrency]
+	delete(statistics, concurrency)
+
+	// 与轮次之间一样等待对照轮次的请求完全结束
+	select {
+	case <-ctx.Done():
+	case <-time.After(30 * time.Second):
+	}
+	return control
+}
+
+// comparePrefixCache 对比共享前缀的轮次和对照轮次，结果记录在共享前缀轮次的统计结果中
+func comparePrefixCache(cfg *config.Config, shared, control *StatisticsSummary) {
+	shared.PrefixCache = &PrefixCacheSummary{
+		SharedPrefixTokens:                 cfg.SharedPrefix.Tokens,
+		ControlSuccess:                     control.Success,
+		ControlAvgTimeClientSide:           control.AvgTimeClientSide,
+		ControlFirstTokenTime:              control.FirstTokenTime,
+		ControlServerOutputTokensPerSecond: control.ServerOutputTokensPerSecond,
+		ControlRequestPerSecond:            control.RequestPerSecond,
+		LatencyReduction:                   reduction(shared.AvgTimeClientSide, control.AvgTimeClientSide),
+		FirstTokenTimeReduction:            reduction(shared.FirstTokenTime, control.FirstTokenTime),
+		ThroughputGain:                     -reduction(shared.ServerOutputTokensPerSecond, control.ServerOutputTokensPerSecond),
+	}
+	p := shared.PrefixCache
+	log.Infof("  prefix cache (%v shared tokens): avg %.1f -> %.1f ms (%.1f%% lower) "+
+		"| FirstToken: %.1f -> %.1f ms (%.1f%% lower) | Server: %.1f -> %.1f tokens/s (%.1f%% higher)",
+		p.SharedPrefixTokens, control.AvgTimeClientSide, shared.AvgTimeClientSide, p.LatencyReduction,
+		control.FirstTokenTime, shared.FirstTokenTime, p.FirstTokenTimeReduction,
+		control.ServerOutputTokensPerSecond, shared.ServerOutputTokensPerSecond, p.ThroughputGain)
+}
+
+// reduction 返回 v 相对 base 降低的百分比，base 为 0 时返回 0
+func reduction(v, base float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return (base - v) / base * 100
+}
@@ -0,0 +1,94 @@
+package throughput
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/exporter"
+	"github.com/nullxjx/llm_profiler/internal/infer/param"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// recorder 在请求返回时逐条把结果以 jsonl 格式写入文件，同时累计本轮的统计指标，
+// 避免在内存中保存一整轮的结果，进程崩溃时也只会丢失最后一条记录
+type recorder struct {
+	file       *os.File
+	encoder    *json.Encoder
+	resultText string
+	acc        *resultAccumulator
+	dash       *dashboard     // 开启终端面板时，同时把结果推送到面板
+	pairs      *pairCollector // A/B 对比测试时，同时把结果按配对编号收集
+	side       int            // A/B 对比测试中结果所属的服务
+	done       chan struct{}
+}
+
+// newRecorder 创建结果文件，文件创建失败时只累计统计指标，不写入文件
+func newRecorder(cfg *config.Config, path string) *recorder {
+	r := &recorder{
+		resultText: cfg.ResultText,
+		acc:        newResultAccumulator(cfg.TimeThresholds),
+		done:       make(chan struct{}),
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		log.Errorf("failed to create directories: %v", err)
+		return r
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		log.Errorf("Error creating file: %v", err)
+		return r
+	}
+	r.file = file
+	r.encoder = json.NewEncoder(file)
+	return r
+}
+
+// run 消费 results 直到其被关闭
+func (r *recorder) run(results <-chan param.Result) {
+	defer close(r.done)
+	for result := range results {
+		if r.side == sideBaseline {
+			exporter.RequestSucceeded(&result)
+		}
+		r.dash.observe(&result)
+		r.acc.add(&result)
+		r.pairs.add(r.side, &result)
+		r.write(&result)
+	}
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			log.Errorf("Error closing results file: %v", err)
+		}
+	}
+}
+
+// wait 等待所有结果都被处理完
+func (r *recorder) wait() {
+	<-r.done
+}
+
+func (r *recorder) write(result *param.Result) {
+	if r.encoder == nil {
+		return
+	}
+	switch r.resultText {
+	case config.ResultTextNone:
+		result.Prompt, result.Output = "", ""
+	case config.ResultTextHash:
+		result.PromptHash, result.OutputHash = hash(result.Prompt), hash(result.Output)
+		result.Prompt, result.Output = "", ""
+	}
+	if err := r.encoder.Encode(result); err != nil {
+		log.Errorf("Error writing result: %v", err)
+	}
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,87 @@
+package throughput
+
+import (
+	"context"
+	"time"
+)
+
+// maxAcceptableLag 单条请求实际发送时间比计划时间晚超过该值时，认为客户端跟不上设定的发送速率
+const maxAcceptableLag = time.Second
+
+// scheduler 预先计算一轮中每条请求的计划发送时间，并记录实际发送时间与计划时间的延迟。
+// 计划时间都相对本轮开始时间计算，单条请求发送延迟不会累积到后续请求上
+type scheduler struct {
+	start    time.Time
+	interval time.Duration
+	times    []time.Time // 每条请求的计划发送时间
+	sent     int
+	totalLag time.Duration
+	maxLag   time.Duration
+	last     time.Time // 最后一条请求的实际发送时间
+}
+
+// dispatchStats 一轮请求的发送情况统计
+type dispatchStats struct {
+	Sent         int     // 实际发送的请求数
+	TargetRate   float64 // 设定的发送速率，单位 req/s
+	AchievedRate float64 // 实际的发送速率，单位 req/s
+	AvgLag       float64 // 平均发送延迟，单位毫秒
+	MaxLag       float64 // 最大发送延迟，单位毫秒
+}
+
+func newScheduler(start time.Time, duration time.Duration, count int) *scheduler {
+	s := &scheduler{
+		start:    start,
+		interval: duration / time.Duration(count),
+		times:    make([]time.Time, count),
+	}
+	for i := range s.times {
+		s.times[i] = start.Add(time.Duration(i) * s.interval)
+	}
+	return s
+}
+
+// wait 等待到第 i 条请求的计划发送时间，返回实际发送延迟，ctx 被取消时返回 false
+func (s *scheduler) wait(ctx context.Context, i int) (time.Duration, bool) {
+	if d := time.Until(s.times[i]); d > 0 {
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, false
+		case <-timer.C:
+		}
+	} else if ctx.Err() != nil {
+		return 0, false
+	}
+	now := time.Now()
+	lag := now.Sub(s.times[i])
+	s.sent++
+	s.totalLag += lag
+	if lag > s.maxLag {
+		s.maxLag = lag
+	}
+	s.last = now
+	return lag, true
+}
+
+// stats 统计本轮的发送情况，实际发送速率按照第一条请求到最后一条请求再加一个发送间隔计算
+func (s *scheduler) stats() *dispatchStats {
+	d := &dispatchStats{
+		Sent:       s.sent,
+		TargetRate: float64(time.Second) / float64(s.interval),
+	}
+	if s.sent == 0 {
+		return d
+	}
+	window := s.last.Sub(s.start) + s.interval
+	d.AchievedRate = float64(s.sent) / window.Seconds()
+	d.AvgLag = float64(s.totalLag.Microseconds()) / 1000 / float64(s.sent)
+	d.MaxLag = float64(s.maxLag.Microseconds()) / 1000
+	return d
+}
+
+// fallBehind 判断客户端是否没能按照设定的速率发送请求
+func (d *dispatchStats) fallBehind() bool {
+	return d.AchievedRate < d.TargetRate*0.95 || d.MaxLag > float64(maxAcceptableLag.Milliseconds())
+}
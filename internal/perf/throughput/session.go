@@ -0,0 +1,53 @@
+package throughput
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nullxjx/llm_profiler/internal/infer/param"
+	"github.com/nullxjx/llm_profiler/internal/prompt"
+)
+
+// runSession 进行一个多轮对话，每轮在历史对话后追加上一轮的模型回复和新的用户消息，某一轮失败或测试被中断时结束会话
+func runSession(req *param.RequestParam, userMessages []string) {
+	defer req.Wg.Done()
+	cfg := req.Config
+	var history []prompt.Message
+	if system := cfg.Prompt.GetSystemPrompt(); system != "" {
+		history = append(history, prompt.Message{Role: prompt.RoleSystem, Content: system})
+	}
+	for t := 0; t < cfg.Session.Turns; t++ {
+		// 对话中用户的消息不够时从头开始循环使用
+		history = append(history, prompt.Message{Role: prompt.RoleUser, Content: userMessages[t%len(userMessages)]})
+		result := make(chan param.Result, 1)
+		turn := *req
+		turn.Wg = &sync.WaitGroup{}
+		turn.Wg.Add(1)
+		turn.Result = result
+		turn.Prompt = prompt.FromMessages(slices.Clone(history))
+		turn.Turn = t + 1
+		if t > 0 {
+			turn.DispatchLag = 0 // 只有第一轮是按计划时间发送的
+		}
+		sendRequest(&turn)
+
+		var res param.Result
+		select {
+		case res = <-result:
+		default:
+			return
+		}
+		req.Result <- res
+		history = append(history, prompt.Message{Role: prompt.RoleAssistant, Content: res.Output})
+
+		if t == cfg.Session.Turns-1 || cfg.Session.ThinkTime <= 0 {
+			continue
+		}
+		select {
+		case <-req.Ctx.Done():
+			return
+		case <-time.After(time.Duration(cfg.Session.ThinkTime) * time.Millisecond):
+		}
+	}
+}
@@ -2,8 +2,9 @@ package throughput
 
 import (
 	"fmt"
-	"time"
+	"math"
 
+	"github.com/nullxjx/llm_profiler/internal/balancer"
 	"github.com/nullxjx/llm_profiler/internal/infer/param"
 	"github.com/nullxjx/llm_profiler/internal/utils"
 
@@ -17,6 +18,8 @@ type StatisticsSummary struct {
 	Success                     int32          `json:"success"`                         // 请求成功数
 	Fail                        int32          `json:"fail"`                            // 请求失败数
 	Total                       int32          `json:"total"`                           // 请求总数
+	Canceled                    int32          `json:"canceled,omitempty"`              // 因测试中断被取消的请求数
+	Partial                     bool           `json:"partial,omitempty"`               // 本轮次是否因测试中断而未完整执行
 	AvgTimeServerSide           float64        `json:"avg_time_server_side"`            // 客户端平均耗时
 	AvgTimeClientSide           float64        `json:"avg_time_client_side"`            // 总耗时/请求数，描述了服务端观察到的请求平均耗时
 	AvgInputLen                 float64        `json:"avg_input_len"`                   // 平均输入字符数
@@ -28,106 +31,383 @@ type StatisticsSummary struct {
 	ClientOutputTokensPerSecond float64        `json:"client_output_tokens_per_second"` // 客户端平均每秒输出token，仅在流式场景下存在
 	FirstTokenTime              float64        `json:"first_token_time"`                // 首token时间，仅在流式场景下存在
 	RequestPerSecond            float64        `json:"request_per_second"`              // 平均每秒处理的请求数
+	TargetRate                  float64        `json:"target_rate"`                     // 设定的每秒发送请求数
+	AchievedRate                float64        `json:"achieved_rate"`                   // 实际的每秒发送请求数
+	AvgDispatchLag              float64        `json:"avg_dispatch_lag"`                // 实际发送时间比计划发送时间的平均延迟，毫秒
+	MaxDispatchLag              float64        `json:"max_dispatch_lag"`                // 实际发送时间比计划发送时间的最大延迟，毫秒
 	TimeSpentSummary            map[string]int `yaml:"time_spent_summary"`              // 不同时间内的请求数量统计
 	StartTime                   string         `json:"start_time"`                      // 本轮次开始时间
 	EndTime                     string         `json:"end_time"`                        // 本轮次结束时间
 	P99                         float64        `yaml:"p99"`                             // 毫秒
 	P90                         float64        `yaml:"p90"`                             // 毫秒
 	P80                         float64        `yaml:"p80"`                             // 毫秒
+	// 服务没有返回输出token数且无法计数的成功请求数，这些请求不计入平均输出token数和每秒输出token
+	UnknownOutputTokens int `json:"unknown_output_tokens,omitempty"`
+
+	Sequences *SequenceSummary `json:"sequences,omitempty"` // 每条请求返回多个序列（n > 1、best_of 或 beam search）时按序列统计的结果
+
+	Endpoints map[string]*EndpointSummary `json:"endpoints,omitempty"` // 配置了多个副本地址时每个地址的统计结果
+	Imbalance *ImbalanceSummary           `json:"imbalance,omitempty"` // 多个副本地址之间的不均衡程度
+	AB        *ABSummary                  `json:"ab,omitempty"`        // A/B 对比测试中对比服务的统计结果和配对差值
+
+	Classes map[string]*ClassSummary `json:"classes,omitempty"` // 混合负载中每类请求的统计结果
+	Models  map[string]*ClassSummary `json:"models,omitempty"`  // 模型混合中每个模型的统计结果
+	Turns   []*ClassSummary          `json:"turns,omitempty"`   // 多轮对话负载中每一轮请求的统计结果，第 i 项为第 i+1 轮
+	// 按比例切换是否忽略 eos 时 forced 和 natural 两组请求的统计结果，对比两组的平均输出token数、耗时和客户端速度
+	EosModes map[string]*ClassSummary `json:"eos_modes,omitempty"`
+
+	PrefixCache *PrefixCacheSummary `json:"prefix_cache,omitempty"` // 共享前缀与对照轮次的对比，只有开启对照测试时存在
+}
+
+// SequenceSummary 每条请求返回多个序列时按序列统计的结果，按请求统计的结果中输出token数为所有序列之和
+type SequenceSummary struct {
+	AvgSequences                  float64 `json:"avg_sequences"`                     // 平均每条请求返回的序列数
+	SequencesPerSecond            float64 `json:"sequences_per_second"`              // 每秒生成的序列数
+	AvgOutputTokensPerSequence    float64 `json:"avg_output_tokens_per_sequence"`    // 每个序列的平均输出token数
+	ClientSequenceTokensPerSecond float64 `json:"client_sequence_tokens_per_second"` // 客户端每个序列的每秒输出token，仅在流式场景下存在
+}
+
+// ABSummary A/B 对比测试中对比服务的统计结果和配对比较的结果，差值均为 candidate 减去基线
+type ABSummary struct {
+	Baseline                    string             `json:"baseline"`                                  // 基线服务的名称
+	Candidate                   string             `json:"candidate"`                                 // 对比服务的名称
+	CandidateStatistics         *StatisticsSummary `json:"candidate_statistics"`                      // 对比服务本轮的统计结果
+	Pairs                       int                `json:"pairs"`                                     // 两个服务都成功的请求对数
+	CandidateFaster             float64            `json:"candidate_faster"`                          // 对比服务耗时更短的请求对占比，百分比
+	Latency                     *PairedDiff        `json:"latency,omitempty"`                         // 耗时差值，毫秒
+	FirstTokenTime              *PairedDiff        `json:"first_token_time,omitempty"`                // 首token时间差值，仅在流式场景下存在
+	ClientOutputTokensPerSecond *PairedDiff        `json:"client_output_tokens_per_second,omitempty"` // 客户端每秒输出token差值，仅在流式场景下存在
+	ThroughputGain              float64            `json:"throughput_gain"`                           // 每秒输出token提升的百分比
+	RequestPerSecondGain        float64            `json:"request_per_second_gain"`                   // 每秒请求数提升的百分比
+}
+
+// PairedDiff 同一条请求在两个服务上的指标差值的分布
+type PairedDiff struct {
+	Mean     float64 `json:"mean"`     // 差值的均值
+	P50      float64 `json:"p50"`      // 差值的中位数
+	P90      float64 `json:"p90"`      // 差值的 90 分位数
+	Relative float64 `json:"relative"` // 差值均值相对基线均值的百分比
+	PValue   float64 `json:"p_value"`  // 配对 t 检验的 p 值，越小说明差异越显著
+}
+
+// EndpointSummary 一个副本地址的统计结果
+type EndpointSummary struct {
+	Weight int   `json:"weight"` // 权重
+	Sent   int32 `json:"sent"`   // 发送的请求数
+	Fail   int32 `json:"fail"`   // 失败的请求数
+	*ClassSummary
+}
+
+// ImbalanceSummary 多个副本地址之间的不均衡程度，用于发现慢副本
+type ImbalanceSummary struct {
+	MaxShareDeviation float64 `json:"max_share_deviation"`   // 各地址发送请求数占比与权重占比的最大相对偏差，百分比
+	LatencyRatio      float64 `json:"latency_ratio"`         // 平均耗时最长与最短的地址之比
+	Slowest           string  `json:"slowest"`               // 平均耗时最长的地址
+	MostFailed        string  `json:"most_failed,omitempty"` // 失败率最高的地址，没有失败时为空
+}
+
+// PrefixCacheSummary 共享前缀的轮次与前缀各不相同的对照轮次的对比，提升为相对对照轮次的百分比
+type PrefixCacheSummary struct {
+	SharedPrefixTokens                 int     `json:"shared_prefix_tokens"`                    // 共享前缀的token数
+	ControlSuccess                     int32   `json:"control_success"`                         // 对照轮次的请求成功数
+	ControlAvgTimeClientSide           float64 `json:"control_avg_time_client_side"`            // 对照轮次的平均耗时，毫秒
+	ControlFirstTokenTime              float64 `json:"control_first_token_time"`                // 对照轮次的首token时间，仅在流式场景下存在
+	ControlServerOutputTokensPerSecond float64 `json:"control_server_output_tokens_per_second"` // 对照轮次的每秒输出token
+	ControlRequestPerSecond            float64 `json:"control_request_per_second"`              // 对照轮次的每秒请求数
+	LatencyReduction                   float64 `json:"latency_reduction"`                       // 平均耗时降低的百分比
+	FirstTokenTimeReduction            float64 `json:"first_token_time_reduction"`              // 首token时间降低的百分比，仅在流式场景下存在
+	ThroughputGain                     float64 `json:"throughput_gain"`                         // 每秒输出token提升的百分比
+}
+
+// ClassSummary 一组请求（混合负载中的一类请求、多轮对话中的一轮请求或同一 eos 模式的请求）的统计结果，只统计成功的请求
+type ClassSummary struct {
+	Success                     int     `json:"success"`                         // 请求成功数
+	AvgTargetInputTokens        float64 `json:"avg_target_input_tokens"`         // 平均采样输入token数
+	AvgTargetOutputTokens       float64 `json:"avg_target_output_tokens"`        // 平均采样最大输出token数
+	AvgInputTokens              float64 `json:"avg_input_tokens"`                // 平均输入token数
+	AvgOutputTokens             float64 `json:"avg_output_tokens"`               // 平均输出token数
+	AvgTimeClientSide           float64 `json:"avg_time_client_side"`            // 平均耗时，毫秒
+	ServerOutputTokensPerSecond float64 `json:"server_output_tokens_per_second"` // 该类请求的每秒输出token
+	ClientOutputTokensPerSecond float64 `json:"client_output_tokens_per_second"` // 客户端平均每秒输出token，仅在流式场景下存在
+	FirstTokenTime              float64 `json:"first_token_time"`                // 首token时间，仅在流式场景下存在
+	P99                         float64 `json:"p99"`                             // 毫秒
+	P90                         float64 `json:"p90"`                             // 毫秒
+	P50                         float64 `json:"p50"`                             // 毫秒
 }
 
 type StatisticsParam struct {
-	Concurrency    int                 // 并发度，即给定时间内发送的请求个数
-	Duration       float64             // 请求持续时间
-	Results        <-chan param.Result // 该轮次调用结果记录
-	TotalCount     int32               // 总请求个数
-	SuccessCount   int32               // 成功请求个数
-	FailedCount    int32               // 失败请求个数
-	TimeThresholds []int64             // 请求时间阈值
-	SaveDir        string              // 保存路径
-	StartTime      string              // 开始时间
-	EndTime        string              // 结束时间
+	Concurrency   int                // 并发度，即给定时间内发送的请求个数
+	Duration      float64            // 请求持续时间
+	Accumulator   *resultAccumulator // 该轮次调用结果的累计值
+	Dispatch      *dispatchStats     // 该轮次请求发送情况
+	Balancer      *balancer.Balancer // 配置了多个副本地址时的负载均衡器，用于统计每个地址的发送和失败数
+	TotalCount    int32              // 总请求个数
+	SuccessCount  int32              // 成功请求个数
+	FailedCount   int32              // 失败请求个数
+	CanceledCount int32              // 取消请求个数
+	Partial       bool               // 是否为被中断的不完整轮次
+	StartTime     string             // 开始时间
+	EndTime       string             // 结束时间
 }
 
 var statistics = make(map[int]*StatisticsSummary) // 记录了每轮次的统计结果
 
-// calMetrics 统计一轮的指标
-func calMetrics(s *StatisticsParam) {
-	var totalTime int64
-	var resultList []param.Result
-	var inputLen int     // 输入字符串的长度
-	var inputTokens int  // 输入token数目
-	var outputLen int    // 输出字符串的长度
-	var outputTokens int //输出token数目
-	var timeSpentList []int64
-	var tokensPerSecond []float64
-	var firstTokenTime []float64
-	timeSpentSummary := make(map[string]int)
-	for result := range s.Results {
-		resultList = append(resultList, result)
-
-		inputLen += result.InputLen
-		inputTokens += result.InputTokens
-		outputLen += result.OutputLen
-		outputTokens += result.OutputTokens
-
-		totalTime += result.TimeSpent
-		timeSpentList = append(timeSpentList, result.TimeSpent)
-		for _, timeThreshold := range s.TimeThresholds {
-			if result.TimeSpent <= timeThreshold {
-				key := fmt.Sprintf("less than %d ms", timeThreshold)
-				timeSpentSummary[key]++
-			}
-		}
-		if result.TokensPerSecond != 0 {
-			tokensPerSecond = append(tokensPerSecond, result.TokensPerSecond)
+// resultAccumulator 在请求返回时逐条累计一轮的指标，不保存 prompt 和输出文本
+type resultAccumulator struct {
+	totalTime        int64
+	inputLen         int // 输入字符串的长度
+	inputTokens      int // 输入token数目
+	outputLen        int // 输出字符串的长度
+	outputTokens     int //输出token数目
+	timeSpent        stats.Float64Data
+	tokensPerSecond  meanAccumulator
+	firstTokenTime   meanAccumulator
+	timeSpentSummary map[string]int
+	timeThresholds   []int64
+
+	sequences               int             // 所有请求返回的序列数之和
+	sequenceTokensPerSecond meanAccumulator // 每个序列的客户端输出速度
+
+	success            int // 成功请求数，仅用于分类统计
+	unknownOutputs     int // 输出token数未知的成功请求数，不计入平均输出token数
+	targetInputTokens  int
+	targetOutputTokens int
+	classes            map[string]*resultAccumulator // 混合负载中每类请求的累计值
+	turns              []*resultAccumulator          // 多轮对话负载中每一轮请求的累计值
+	eosModes           map[string]*resultAccumulator // 每种 eos 模式的请求的累计值
+	endpoints          map[string]*resultAccumulator // 每个副本地址的请求的累计值
+	models             map[string]*resultAccumulator // 模型混合中每个模型的请求的累计值
+}
+
+func newResultAccumulator(timeThresholds []int64) *resultAccumulator {
+	return &resultAccumulator{
+		timeSpentSummary: make(map[string]int),
+		timeThresholds:   timeThresholds,
+		classes:          make(map[string]*resultAccumulator),
+		eosModes:         make(map[string]*resultAccumulator),
+		endpoints:        make(map[string]*resultAccumulator),
+		models:           make(map[string]*resultAccumulator),
+	}
+}
+
+// add 累计一条请求结果
+func (a *resultAccumulator) add(result *param.Result) {
+	a.inputLen += result.InputLen
+	a.inputTokens += result.InputTokens
+	a.outputLen += result.OutputLen
+	a.outputTokens += result.OutputTokens
+
+	a.totalTime += result.TimeSpent
+	a.timeSpent = append(a.timeSpent, float64(result.TimeSpent))
+	for _, timeThreshold := range a.timeThresholds {
+		if result.TimeSpent <= timeThreshold {
+			key := fmt.Sprintf("less than %d ms", timeThreshold)
+			a.timeSpentSummary[key]++
 		}
-		if result.FirstTokenTime != 0 {
-			firstTokenTime = append(firstTokenTime, result.FirstTokenTime)
+	}
+	if result.TokensPerSecond != 0 {
+		a.tokensPerSecond.add(result.TokensPerSecond)
+	}
+	if result.FirstTokenTime != 0 {
+		a.firstTokenTime.add(result.FirstTokenTime)
+	}
+	sequences := max(1, result.Sequences)
+	a.sequences += sequences
+	if result.TokensPerSecond != 0 {
+		a.sequenceTokensPerSecond.add(result.TokensPerSecond / float64(sequences))
+	}
+	a.success++
+	if result.OutputTokensUnknown {
+		a.unknownOutputs++
+	}
+	a.targetInputTokens += result.TargetInputTokens
+	a.targetOutputTokens += result.TargetOutputTokens
+	if a.classes == nil { // 分组的累计值不再分组
+		return
+	}
+	if result.Class != "" {
+		group(a.classes, result.Class).add(result)
+	}
+	if result.EosMode != "" {
+		group(a.eosModes, result.EosMode).add(result)
+	}
+	if result.Endpoint != "" {
+		group(a.endpoints, result.Endpoint).add(result)
+	}
+	if result.Model != "" {
+		group(a.models, result.Model).add(result)
+	}
+	if result.Turn > 0 {
+		for len(a.turns) < result.Turn {
+			a.turns = append(a.turns, &resultAccumulator{timeSpentSummary: make(map[string]int)})
 		}
+		a.turns[result.Turn-1].add(result)
+	}
+}
+
+// sequenceSummary 按序列统计的结果，所有请求都只返回一个序列时返回 nil
+func (a *resultAccumulator) sequenceSummary(duration float64) *SequenceSummary {
+	if a.sequences <= a.success {
+		return nil
+	}
+	return &SequenceSummary{
+		AvgSequences:                  float64(a.sequences) / float64(a.success),
+		SequencesPerSecond:            float64(a.sequences) / duration,
+		AvgOutputTokensPerSequence:    float64(a.outputTokens) / float64(a.sequences),
+		ClientSequenceTokensPerSecond: a.sequenceTokensPerSecond.meanWithoutMinMax(),
+	}
+}
+
+// avgOutputTokens 输出token数已知的请求的平均输出token数
+func (a *resultAccumulator) avgOutputTokens() float64 {
+	if n := a.success - a.unknownOutputs; n > 0 {
+		return float64(a.outputTokens) / float64(n)
+	}
+	return 0
+}
+
+// group 返回分组的累计值，不存在时创建
+func group(groups map[string]*resultAccumulator, key string) *resultAccumulator {
+	g, ok := groups[key]
+	if !ok {
+		g = &resultAccumulator{timeSpentSummary: make(map[string]int)}
+		groups[key] = g
+	}
+	return g
+}
+
+// groupSummaries 统计每组请求的指标，duration 为本轮耗时，单位是秒
+func groupSummaries(groups map[string]*resultAccumulator, duration float64) map[string]*ClassSummary {
+	if len(groups) == 0 {
+		return nil
+	}
+	summaries := make(map[string]*ClassSummary, len(groups))
+	for name, g := range groups {
+		summaries[name] = g.summary(duration)
+	}
+	return summaries
+}
+
+// turnSummaries 统计多轮对话中每一轮请求的指标，duration 为本轮耗时，单位是秒
+func (a *resultAccumulator) turnSummaries(duration float64) []*ClassSummary {
+	summaries := make([]*ClassSummary, 0, len(a.turns))
+	for _, t := range a.turns {
+		summaries = append(summaries, t.summary(duration))
+	}
+	return summaries
+}
+
+// summary 根据累计值统计一组请求的指标
+func (a *resultAccumulator) summary(duration float64) *ClassSummary {
+	n := float64(a.success)
+	if n == 0 {
+		return &ClassSummary{}
+	}
+	p99, _ := stats.Percentile(a.timeSpent, 99)
+	p90, _ := stats.Percentile(a.timeSpent, 90)
+	p50, _ := stats.Percentile(a.timeSpent, 50)
+	return &ClassSummary{
+		Success:                     a.success,
+		AvgTargetInputTokens:        float64(a.targetInputTokens) / n,
+		AvgTargetOutputTokens:       float64(a.targetOutputTokens) / n,
+		AvgInputTokens:              float64(a.inputTokens) / n,
+		AvgOutputTokens:             a.avgOutputTokens(),
+		AvgTimeClientSide:           float64(a.totalTime) / n,
+		ServerOutputTokensPerSecond: float64(a.outputTokens) / duration,
+		ClientOutputTokensPerSecond: a.tokensPerSecond.meanWithoutMinMax(),
+		FirstTokenTime:              a.firstTokenTime.meanWithoutMinMax(),
+		P99:                         p99,
+		P90:                         p90,
+		P50:                         p50,
+	}
+}
+
+// meanAccumulator 流式计算去掉最大最小值后的均值，结果与 utils.MeanWithoutMinMax 一致
+type meanAccumulator struct {
+	sum   float64
+	min   float64
+	max   float64
+	count int
+}
+
+func (m *meanAccumulator) add(v float64) {
+	if m.count == 0 || v < m.min {
+		m.min = v
 	}
+	if m.count == 0 || v > m.max {
+		m.max = v
+	}
+	m.sum += v
+	m.count++
+}
+
+func (m *meanAccumulator) meanWithoutMinMax() float64 {
+	if m.count < utils.MinimumCount {
+		return 0
+	}
+	return (m.sum - m.min - m.max) / float64(m.count-2)
+}
 
+// calMetrics 统计一轮的指标，由调用方决定是否保存到统计缓存中
+func calMetrics(s *StatisticsParam) *StatisticsSummary {
+	acc := s.Accumulator
 	var avgTimeServerSide float64 = 0
 	var avgTimeClientSide float64 = 0
+	var avgInputTokens, avgOutputTokens, avgInputLen, avgOutputLen float64
 	if s.SuccessCount > 0 {
 		avgTimeServerSide = s.Duration * 1000 / float64(s.SuccessCount)
-		avgTimeClientSide = float64(totalTime) / float64(s.SuccessCount)
+		avgTimeClientSide = float64(acc.totalTime) / float64(s.SuccessCount)
+		// 被中断的轮次可能没有成功请求，避免出现 NaN 导致结果无法保存
+		avgInputTokens = float64(acc.inputTokens) / float64(s.SuccessCount)
+		avgOutputTokens = acc.avgOutputTokens()
+		avgInputLen = float64(acc.inputLen) / float64(s.SuccessCount)
+		avgOutputLen = float64(acc.outputLen) / float64(s.SuccessCount)
 	}
-	nowStr := time.Now().Format(utils.TimeFormat)
-	utils.Save2Json(resultList, fmt.Sprintf("%s/results_%s_concurrency_%d.json", s.SaveDir, nowStr, s.Concurrency))
 
-	// 将 int64 数据转换为 float64 类型
-	floatData := make(stats.Float64Data, len(timeSpentList))
-	for i, v := range timeSpentList {
-		floatData[i] = float64(v)
-	}
 	// 计算 P99, P90, 和 P80
-	p99, _ := stats.Percentile(floatData, 99)
-	p90, _ := stats.Percentile(floatData, 90)
-	p80, _ := stats.Percentile(floatData, 80)
-	statistics[s.Concurrency] = &StatisticsSummary{
+	p99, _ := stats.Percentile(acc.timeSpent, 99)
+	p90, _ := stats.Percentile(acc.timeSpent, 90)
+	p80, _ := stats.Percentile(acc.timeSpent, 80)
+	summary := &StatisticsSummary{
 		Concurrency:                 s.Concurrency,
 		Success:                     s.SuccessCount,
 		Fail:                        s.FailedCount,
 		Total:                       s.TotalCount,
+		Canceled:                    s.CanceledCount,
+		Partial:                     s.Partial,
 		AvgTimeServerSide:           avgTimeServerSide,
 		AvgTimeClientSide:           avgTimeClientSide,
-		AvgInputTokens:              float64(inputTokens) / float64(s.SuccessCount),
-		AvgOutputTokens:             float64(outputTokens) / float64(s.SuccessCount),
-		AvgInputLen:                 float64(inputLen) / float64(s.SuccessCount),
-		AvgOutputLen:                float64(outputLen) / float64(s.SuccessCount),
-		ServerInputTokensPerSecond:  float64(inputTokens) / s.Duration,
-		ServerOutputTokensPerSecond: float64(outputTokens) / s.Duration,
-		ClientOutputTokensPerSecond: utils.MeanWithoutMinMax(tokensPerSecond), // 仅在流式场景下存在
-		FirstTokenTime:              utils.MeanWithoutMinMax(firstTokenTime),  // 仅在流式场景下存在
+		AvgInputTokens:              avgInputTokens,
+		AvgOutputTokens:             avgOutputTokens,
+		AvgInputLen:                 avgInputLen,
+		AvgOutputLen:                avgOutputLen,
+		ServerInputTokensPerSecond:  float64(acc.inputTokens) / s.Duration,
+		ServerOutputTokensPerSecond: float64(acc.outputTokens) / s.Duration,
+		ClientOutputTokensPerSecond: acc.tokensPerSecond.meanWithoutMinMax(), // 仅在流式场景下存在
+		FirstTokenTime:              acc.firstTokenTime.meanWithoutMinMax(),  // 仅在流式场景下存在
 		RequestPerSecond:            float64(s.SuccessCount) / s.Duration,
-		TimeSpentSummary:            timeSpentSummary,
+		TargetRate:                  s.Dispatch.TargetRate,
+		AchievedRate:                s.Dispatch.AchievedRate,
+		AvgDispatchLag:              s.Dispatch.AvgLag,
+		MaxDispatchLag:              s.Dispatch.MaxLag,
+		TimeSpentSummary:            acc.timeSpentSummary,
 		StartTime:                   s.StartTime,
 		EndTime:                     s.EndTime,
 		P99:                         p99,
 		P90:                         p90,
 		P80:                         p80,
+		Sequences:                   acc.sequenceSummary(s.Duration),
+		Classes:                     groupSummaries(acc.classes, s.Duration),
+		Models:                      groupSummaries(acc.models, s.Duration),
+		EosModes:                    groupSummaries(acc.eosModes, s.Duration),
+		Turns:                       acc.turnSummaries(s.Duration),
+		UnknownOutputTokens:         acc.unknownOutputs,
 	}
+	if s.Balancer != nil {
+		summary.Endpoints = endpointSummaries(s.Balancer, acc, s.Duration)
+		summary.Imbalance = imbalance(summary.Endpoints)
+	}
+	return summary
 }
 
 func clearCache() {
@@ -152,3 +432,53 @@ func GetMaxThroughput() (float64, float64, float64) {
 
 	return maxRequestPerSecond, maxInputTokensPerSecond, maxOutputTokensPerSecond
 }
+
+// endpointSummaries 统计每个副本地址的指标，没有成功请求的地址同样给出发送和失败数
+func endpointSummaries(b *balancer.Balancer, acc *resultAccumulator, duration float64) map[string]*EndpointSummary {
+	summaries := make(map[string]*EndpointSummary, len(b.Endpoints()))
+	for _, e := range b.Endpoints() {
+		s := &EndpointSummary{Weight: e.Weight, Sent: e.Sent(), Fail: e.Failed(), ClassSummary: &ClassSummary{}}
+		if g, ok := acc.endpoints[e.Url]; ok {
+			s.ClassSummary = g.summary(duration)
+		}
+		summaries[e.Url] = s
+	}
+	return summaries
+}
+
+// imbalance 计算多个副本地址之间的不均衡程度
+func imbalance(endpoints map[string]*EndpointSummary) *ImbalanceSummary {
+	var sent int32
+	weight := 0
+	for _, e := range endpoints {
+		sent += e.Sent
+		weight += e.Weight
+	}
+	if sent == 0 || weight == 0 {
+		return nil
+	}
+	res := &ImbalanceSummary{}
+	var fastest, slowest, failRate float64
+	for url, e := range endpoints {
+		if e.Weight > 0 {
+			expected := float64(e.Weight) / float64(weight)
+			share := float64(e.Sent) / float64(sent)
+			res.MaxShareDeviation = max(res.MaxShareDeviation, math.Abs(share-expected)/expected*100)
+		}
+		if e.Success > 0 {
+			if fastest == 0 || e.AvgTimeClientSide < fastest {
+				fastest = e.AvgTimeClientSide
+			}
+			if e.AvgTimeClientSide > slowest {
+				slowest, res.Slowest = e.AvgTimeClientSide, url
+			}
+		}
+		if e.Sent > 0 && e.Fail > 0 && float64(e.Fail)/float64(e.Sent) > failRate {
+			failRate, res.MostFailed = float64(e.Fail)/float64(e.Sent), url
+		}
+	}
+	if fastest > 0 {
+		res.LatencyRatio = slowest / fastest
+	}
+	return res
+}
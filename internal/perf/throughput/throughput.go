@@ -1,103 +1,220 @@
 package throughput
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/balancer"
+	"github.com/nullxjx/llm_profiler/internal/exporter"
 	"github.com/nullxjx/llm_profiler/internal/infer"
 	"github.com/nullxjx/llm_profiler/internal/infer/param"
-	"github.com/nullxjx/llm_profiler/internal/infer/type/backend"
+	"github.com/nullxjx/llm_profiler/internal/prompt"
 	"github.com/nullxjx/llm_profiler/internal/utils"
+	"github.com/nullxjx/llm_profiler/internal/workload"
 	"github.com/nullxjx/llm_profiler/pkg/store/cos"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// StartTest 开始吞吐量测试
-func StartTest(cfg *config.Config) (string, string) {
+// StartTest 开始吞吐量测试，ctx 被取消时会保存当前轮次的不完整结果并结束测试，返回结果的下载链接和cos目录
+// cp 记录了测试进度，每轮结束后都会保存，从断点恢复时从 cp.NextConcurrency 开始测试
+func StartTest(ctx context.Context, cfg *config.Config, cp *Checkpoint) (string, string, error) {
 	clearCache()
+	cp.restore()
+	if cp.Stopped {
+		log.Infof("Test in %v has already finished", cfg.SaveDir)
+		downloadUrl, dstDir := finish(cfg)
+		return downloadUrl, dstDir, nil
+	}
 
-	prompts, err := utils.ReadPrompts(cfg.InputTokens)
+	prompts, err := prompt.Load(cfg)
 	if err != nil {
-		log.Errorf("read inputs error: %v", err)
-		return "", ""
+		return "", "", fmt.Errorf("read inputs error: %v", err)
+	}
+	wl, err := workload.New(cfg, prompts)
+	if err != nil {
+		return "", "", fmt.Errorf("create workload error: %v", err)
+	}
+	bal, err := balancer.New(cfg)
+	if err != nil {
+		return "", "", fmt.Errorf("create load balancer error: %v", err)
+	}
+	cand, err := newCandidate(cfg)
+	if err != nil {
+		return "", "", fmt.Errorf("create ab candidate error: %v", err)
+	}
+	var conversations [][]string
+	if cfg.Session.Enabled() {
+		if conversations, err = prompt.LoadConversations(cfg); err != nil {
+			return "", "", fmt.Errorf("read conversations error: %v", err)
+		}
 	}
 	if cfg.StartConcurrency > cfg.EndConcurrency {
-		log.Errorf("StartConcurrency > EndConcurrency")
-		return "", ""
+		return "", "", fmt.Errorf("StartConcurrency > EndConcurrency")
 	}
 
 	// 逐步增加并发度，测试吞吐量
-	for concurrency := cfg.StartConcurrency; concurrency <= cfg.EndConcurrency; concurrency += cfg.Increment {
+loop:
+	for concurrency := cp.NextConcurrency; concurrency <= cfg.EndConcurrency; concurrency += cfg.Increment {
 		log.Infof("🙏🙏🙏 start testing at concurrency %v, duration: %v min", concurrency, cfg.Duration)
-		step(cfg, prompts, concurrency)
-		if stop(cfg, concurrency) {
+		exporter.SetConcurrency(concurrency)
+		var control *StatisticsSummary
+		if cfg.SharedPrefix.Control {
+			control = controlStep(ctx, cfg, wl, bal, cand, concurrency)
+		}
+		step(ctx, cfg, wl, conversations, bal, cand, concurrency)
+		if control != nil && ctx.Err() == nil {
+			comparePrefixCache(cfg, statistics[concurrency], control)
+		}
+		if ctx.Err() != nil {
+			// 被中断的轮次不参与停止判断，直接保存，恢复时重新测试该轮次
+			log.Warnf("Test interrupted at concurrency %v, saving partial results...", concurrency)
+			saveResult(cfg)
+			cp.update(cfg, concurrency, false)
+			break
+		}
+		stopped := stop(cfg, concurrency)
+		cp.update(cfg, concurrency+cfg.Increment, stopped)
+		if stopped {
 			break
 		}
 		// 等待上一轮完全结束，为了避免这轮还未完成的请求对下一轮造成影响
 		// todo(@nullxjx) 需要改进，如何更精准判断上一轮已经结束
-		time.Sleep(30 * time.Second)
+		select {
+		case <-ctx.Done():
+			log.Warnf("Test interrupted, saving results...")
+			break loop
+		case <-time.After(30 * time.Second):
+		}
 	}
 
-	return finish(cfg)
+	downloadUrl, dstDir := finish(cfg)
+	return downloadUrl, dstDir, nil
 }
 
-// step 进行一轮测试
-func step(cfg *config.Config, prompts []string, concurrency int) {
+// step 进行一轮测试，ctx 被取消时停止发送新请求并取消正在进行的请求
+// 开启多轮对话负载时每次发送开始一个会话，会话的后续轮次在收到上一轮回复后发送
+// 配置了多个副本地址时由 bal 为每条请求选择地址，开启 A/B 对比测试时每条请求同时发送到 cand
+func step(ctx context.Context, cfg *config.Config, wl *workload.Workload, conversations [][]string,
+	bal *balancer.Balancer, cand *candidate, concurrency int) {
 	wg := &sync.WaitGroup{}
-	var mu sync.Mutex
 	results := make(chan param.Result, concurrency)
 	counter := &param.Counter{
 		Success: 0,
 		Failed:  0,
 		Total:   0,
 	}
-	var inputIndex = 0
 	startTime := time.Now()
+	name := fmt.Sprintf("%s/results_%s_concurrency_%d", cfg.SaveDir, startTime.Format(utils.TimeFormat), concurrency)
+	if wl.Control() {
+		name += "_control"
+	}
+	rec := newRecorder(cfg, name+".jsonl")
+	if cfg.Tui {
+		rec.dash = newDashboard(os.Stdout, concurrency, counter, sortedStatistics())
+		rec.dash.start()
+		defer rec.dash.close()
+	}
+	var pairs *pairCollector
+	var candRound *candidateRound
+	if cand != nil {
+		pairs = newPairCollector()
+		rec.pairs = pairs
+		candRound = cand.start(name, concurrency, pairs)
+	}
+	go rec.run(results)
 	duration := time.Duration(cfg.Duration) * time.Minute
-	ticker := time.NewTicker(duration / time.Duration(concurrency))
-	for start := time.Now(); time.Since(start) < duration; {
-		select {
-		case <-ticker.C:
-			wg.Add(1)
-			go sendRequest(&param.RequestParam{
-				Wg:      wg,
-				Prompt:  prompts[inputIndex],
-				Result:  results,
-				Counter: counter,
-				Config:  cfg,
-			})
-		default:
-			time.Sleep(10 * time.Millisecond) // Avoid busy waiting
+	sched := newScheduler(startTime, duration, concurrency)
+	wl.Reset(concurrency)
+	if bal != nil {
+		bal.Reset()
+	}
+	for i := 0; i < concurrency; i++ {
+		// 在等待发送时间之前采样，避免采样耗时影响发送时间
+		r, err := wl.Next(i)
+		if err != nil {
+			log.Errorf("Generate request input error: %v", err)
+			break
+		}
+		lag, ok := sched.wait(ctx, i)
+		if !ok {
+			break
 		}
-		// 使用互斥锁保护对 inputIndex 的访问和更新
-		mu.Lock()
-		inputIndex = (inputIndex + 1) % len(prompts)
-		mu.Unlock()
+		wg.Add(1)
+		rec.dash.dispatched()
+		req := &param.RequestParam{
+			Ctx:               ctx,
+			Wg:                wg,
+			Prompt:            r.Prompt,
+			DispatchLag:       float64(lag.Microseconds()) / 1000,
+			Result:            results,
+			Counter:           counter,
+			Config:            cfg,
+			Class:             r.Class,
+			TargetInputTokens: r.InputTokens,
+			MaxTokens:         r.MaxTokens,
+			StopAtEos:         r.StopAtEos,
+			EosMode:           r.EosMode,
+			Balancer:          bal,
+			Model:             r.Model,
+		}
+		if conversations != nil {
+			req.Session = i + 1
+			go runSession(req, conversations[i%len(conversations)])
+			continue
+		}
+		if candRound != nil {
+			req.Pair = i + 1
+			candRound.send(req)
+		}
+		go sendRequest(req)
+	}
+	dispatch := sched.stats()
+	if dispatch.fallBehind() {
+		log.Warnf("Client can not keep up with the target rate %.2f req/s, achieved: %.2f req/s, "+
+			"max dispatch lag: %.1f ms, results may underestimate the server load",
+			dispatch.TargetRate, dispatch.AchievedRate, dispatch.MaxLag)
 	}
 	log.Debugf("Waiting for all goroutines to finish...")
 	wg.Wait() // 阻塞，直到 WaitGroup 的计数器变为 0
 	close(results)
+	rec.wait()
+	if candRound != nil {
+		candRound.finish()
+	}
 
 	endTime := time.Now()
 	timeSpent := float64(endTime.Sub(startTime)) / float64(time.Second)
-	calMetrics(&StatisticsParam{
-		Concurrency:    concurrency,
-		Duration:       timeSpent, // 单位是秒
-		Results:        results,
-		TotalCount:     counter.Total,
-		SuccessCount:   counter.Success,
-		FailedCount:    counter.Failed,
-		TimeThresholds: cfg.TimeThresholds,
-		SaveDir:        cfg.SaveDir,
-		StartTime:      startTime.Format(utils.TimeFormat),
-		EndTime:        endTime.Format(utils.TimeFormat),
-	})
-	metric := statistics[concurrency]
+	partial := ctx.Err() != nil
+	sp := StatisticsParam{
+		Concurrency:   concurrency,
+		Duration:      timeSpent, // 单位是秒
+		Accumulator:   rec.acc,
+		Dispatch:      dispatch,
+		Balancer:      bal,
+		TotalCount:    counter.Total,
+		SuccessCount:  counter.Success,
+		FailedCount:   counter.Failed,
+		CanceledCount: counter.Canceled,
+		Partial:       partial,
+		StartTime:     startTime.Format(utils.TimeFormat),
+		EndTime:       endTime.Format(utils.TimeFormat),
+	}
+	metric := calMetrics(&sp)
+	statistics[concurrency] = metric
+	if metric.UnknownOutputTokens > 0 {
+		log.Warnf("%v requests have unknown output tokens, set prompt.tokenizer to count them, "+
+			"token throughput only includes the other requests", metric.UnknownOutputTokens)
+	}
+	if partial {
+		log.Warnf("Round at concurrency %v was interrupted, %v requests canceled, statistics are partial",
+			concurrency, metric.Canceled)
+	}
 	if cfg.Stream {
 		log.Infof("[time: %.1f s, total: %v, success: %v, fail: %v] "+
 			"| Server: [ %.1f tokens/s, %.1f req/s ] | Client: %.1f tokens/s "+
@@ -107,45 +224,137 @@ func step(cfg *config.Config, prompts []string, concurrency int) {
 			metric.ServerOutputTokensPerSecond, metric.RequestPerSecond, metric.ClientOutputTokensPerSecond,
 			cfg.StreamThresholds, cfg.MaxStreamSpeed, metric.FirstTokenTime, cfg.InputTokens)
 	} else {
-		log.Info("[time: %.1f s, total: %v, success: %v, fail: %v] "+
+		log.Infof("[time: %.1f s, total: %v, success: %v, fail: %v] "+
 			"| Server: [ %.1f tokens/s, %.1f req/s ] | Prompt length: %v",
 			timeSpent, metric.Total, metric.Success, metric.Fail,
 			metric.ServerOutputTokensPerSecond, metric.RequestPerSecond, cfg.InputTokens)
 	}
+	if seq := metric.Sequences; seq != nil {
+		log.Infof("  sequences: [avg: %.1f per request, %.0f tokens per sequence] | %.1f seq/s "+
+			"| Client: %.1f tokens/s per sequence", seq.AvgSequences, seq.AvgOutputTokensPerSequence, seq.SequencesPerSecond, seq.ClientSequenceTokensPerSecond)
+	}
+	logClasses(metric)
+	logModels(metric)
+	logTurns(metric)
+	logEosModes(metric)
+	logEndpoints(metric)
+	if candRound != nil {
+		candRound.compare(metric, sp, pairs)
+	}
 }
 
-func sendRequest(req *param.RequestParam) {
-	var backendHandlers map[string]func(*param.RequestParam)
-	cfg := req.Config
-	if cfg.Stream {
-		backendHandlers = map[string]func(*param.RequestParam){
-			string(backend.VLLM): infer.SendVllmStreamRequest,
-			string(backend.TRT):  infer.SendTrtStreamRequest,
+// logClasses 输出混合负载中每类请求的统计结果
+func logClasses(metric *StatisticsSummary) {
+	names := make([]string, 0, len(metric.Classes))
+	for name := range metric.Classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := metric.Classes[name]
+		log.Infof("  class %v: [success: %v, input: %.0f tokens, output: %.0f/%.0f tokens] "+
+			"| %.1f tokens/s | avg: %.1f ms, P90: %.1f ms, P99: %.1f ms | FirstToken: %.1f ms",
+			name, c.Success, c.AvgTargetInputTokens, c.AvgOutputTokens, c.AvgTargetOutputTokens,
+			c.ServerOutputTokensPerSecond, c.AvgTimeClientSide, c.P90, c.P99, c.FirstTokenTime)
+	}
+}
+
+// logModels 输出模型混合中每个模型的统计结果，用于比较切换 LoRA adapter 的开销
+func logModels(metric *StatisticsSummary) {
+	names := make([]string, 0, len(metric.Models))
+	for name := range metric.Models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m := metric.Models[name]
+		log.Infof("  model %v: [success: %v, input: %.0f tokens, output: %.0f tokens] | %.1f tokens/s "+
+			"| avg: %.1f ms, P90: %.1f ms, P99: %.1f ms | FirstToken: %.1f ms",
+			name, m.Success, m.AvgInputTokens, m.AvgOutputTokens, m.ServerOutputTokensPerSecond,
+			m.AvgTimeClientSide, m.P90, m.P99, m.FirstTokenTime)
+	}
+}
+
+// logTurns 输出多轮对话负载中每一轮请求的上下文长度和延迟
+func logTurns(metric *StatisticsSummary) {
+	for i, t := range metric.Turns {
+		log.Infof("  turn %v: [success: %v, context: %.0f tokens, output: %.0f tokens] "+
+			"| avg: %.1f ms, P90: %.1f ms, P99: %.1f ms | FirstToken: %.1f ms",
+			i+1, t.Success, t.AvgInputTokens, t.AvgOutputTokens, t.AvgTimeClientSide, t.P90, t.P99, t.FirstTokenTime)
+	}
+}
+
+// logEosModes 对比忽略 eos 和遇到 eos 时停止的两组请求，natural 组的实际输出长度占目标长度的比例反映了提前停止的程度
+func logEosModes(metric *StatisticsSummary) {
+	for _, mode := range []string{config.EosForced, config.EosNatural} {
+		m, ok := metric.EosModes[mode]
+		if !ok {
+			continue
 		}
-	} else {
-		backendHandlers = map[string]func(*param.RequestParam){
-			string(backend.VLLM): infer.SendVllmRequest,
-			string(backend.TRT):  infer.SendTrtRequest,
-			string(backend.TGI):  infer.SendTgiRequest,
+		ratio := 0.
+		if m.AvgTargetOutputTokens > 0 {
+			ratio = m.AvgOutputTokens / m.AvgTargetOutputTokens * 100
 		}
+		log.Infof("  eos %v: [success: %v, output: %.0f/%.0f tokens (%.0f%%)] | %.1f tokens/s "+
+			"| avg: %.1f ms, P90: %.1f ms, P99: %.1f ms | Client: %.1f tokens/s, FirstToken: %.1f ms",
+			mode, m.Success, m.AvgOutputTokens, m.AvgTargetOutputTokens, ratio, m.ServerOutputTokensPerSecond,
+			m.AvgTimeClientSide, m.P90, m.P99, m.ClientOutputTokensPerSecond, m.FirstTokenTime)
+	}
+}
+
+// logEndpoints 输出每个副本地址的统计结果和不均衡程度，用于发现慢副本
+func logEndpoints(metric *StatisticsSummary) {
+	urls := make([]string, 0, len(metric.Endpoints))
+	for url := range metric.Endpoints {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	for _, url := range urls {
+		e := metric.Endpoints[url]
+		log.Infof("  endpoint %v: [weight: %v, sent: %v, success: %v, fail: %v] | %.1f tokens/s "+
+			"| avg: %.1f ms, P90: %.1f ms, P99: %.1f ms | FirstToken: %.1f ms",
+			url, e.Weight, e.Sent, e.Success, e.Fail, e.ServerOutputTokensPerSecond,
+			e.AvgTimeClientSide, e.P90, e.P99, e.FirstTokenTime)
+	}
+	if im := metric.Imbalance; im != nil {
+		log.Infof("  imbalance: [share deviation: %.1f%%, latency ratio: %.2f] | slowest: %v, most failed: %v",
+			im.MaxShareDeviation, im.LatencyRatio, im.Slowest, im.MostFailed)
 	}
-	handler, ok := backendHandlers[strings.ToLower(cfg.Backend)]
+}
+
+func sendRequest(req *param.RequestParam) {
+	cfg := req.Config
+	handler, ok := infer.Handler(cfg.Backend, cfg.Stream)
 	if !ok {
 		panic(fmt.Sprintf("unsupported backend: %s", cfg.Backend))
 	}
-	go handler(req)
+	if req.Balancer != nil {
+		req.Endpoint = req.Balancer.Acquire()
+		defer req.Balancer.Release(req.Endpoint)
+	}
+	if !req.Candidate {
+		// prometheus 指标只统计基线服务，避免 A/B 对比测试时重复计数
+		exporter.RequestSent()
+		defer exporter.RequestDone()
+	}
+	handler(req)
 }
 
 func saveResult(cfg *config.Config) {
+	utils.Save2Json(sortedStatistics(),
+		fmt.Sprintf("%s/statistics_%s.json", cfg.SaveDir, time.Now().Format(utils.TimeFormat)))
+}
+
+// sortedStatistics 返回按照Concurrency排序的统计结果
+func sortedStatistics() []*StatisticsSummary {
 	var values []*StatisticsSummary
 	for _, value := range statistics {
 		values = append(values, value)
 	}
-	// 按照Concurrency对结果排序
 	sort.Slice(values, func(i, j int) bool {
 		return values[i].Concurrency < values[j].Concurrency
 	})
-	utils.Save2Json(values, fmt.Sprintf("%s/statistics_%s.json", cfg.SaveDir, time.Now().Format(utils.TimeFormat)))
+	return values
 }
 
 func finish(cfg *config.Config) (string, string) {
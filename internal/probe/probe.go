@@ -0,0 +1,228 @@
+package probe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nullxjx/llm_profiler/internal/infer/type/backend"
+	"github.com/nullxjx/llm_profiler/pkg/http"
+)
+
+// Model 推理服务中的一个模型
+type Model struct {
+	Name       string         `json:"name"`
+	Parent     string         `json:"parent,omitempty"`      // LoRA adapter 所属的基础模型，仅 vllm 返回
+	MaxContext int            `json:"max_context,omitempty"` // 最大上下文长度，0 表示未知
+	Limits     map[string]int `json:"limits,omitempty"`      // 服务返回的其他长度和批大小限制
+}
+
+// Info 探测到的推理服务信息
+type Info struct {
+	Url     string   `json:"url"`
+	Backend string   `json:"backend"`           // 推理后端类型，与配置中的 backend 一致
+	Version string   `json:"version,omitempty"` // 推理引擎的版本，未知时为空
+	Models  []*Model `json:"models"`
+}
+
+// tritonPreferredModels trt 中优先用于测试的模型，其他模型通常只是其中的一个步骤
+var tritonPreferredModels = []string{"ensemble", "tensorrt_llm_bls"}
+
+// DefaultModel 返回建议使用的模型名称，没有模型时返回空
+func (i *Info) DefaultModel() string {
+	if len(i.Models) == 0 {
+		return ""
+	}
+	if i.Backend == string(backend.TRT) {
+		for _, name := range tritonPreferredModels {
+			if i.Has(name) {
+				return name
+			}
+		}
+	}
+	return i.Models[0].Name
+}
+
+// Has 判断服务中是否有名称为 name 的模型
+func (i *Info) Has(name string) bool {
+	return slices.ContainsFunc(i.Models, func(m *Model) bool {
+		return m.Name == name
+	})
+}
+
+// detectors 依次尝试的识别方式，新版本的 TGI 同样提供 /v1/models，需要先通过 /info 识别
+var detectors = []struct {
+	backend backend.BackendType
+	detect  func(ctx context.Context, url, model string) (*Info, error)
+}{
+	{backend.TGI, detectTgi},
+	{backend.TRT, detectTriton},
+	{backend.VLLM, detectVllm},
+}
+
+// Detect 依次请求各推理后端特有的接口识别后端类型，并读取服务中的模型、上下文长度和版本，
+// model 不为空时 trt 只读取该模型的配置，避免逐个读取模型仓库中的所有模型
+func Detect(ctx context.Context, url, model string) (*Info, error) {
+	url = strings.TrimSuffix(url, "/")
+	var errs []string
+	for _, d := range detectors {
+		info, err := d.detect(ctx, url, model)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", d.backend, err))
+			continue
+		}
+		info.Url, info.Backend = url, string(d.backend)
+		return info, nil
+	}
+	return nil, fmt.Errorf("can not detect backend at %s, %s", url, strings.Join(errs, "; "))
+}
+
+// tgiInfo TGI /info 接口的返回
+type tgiInfo struct {
+	ModelId               string `json:"model_id"`
+	Version               string `json:"version"`
+	MaxInputTokens        int    `json:"max_input_tokens"`
+	MaxInputLength        int    `json:"max_input_length"` // 2.0 之前的版本
+	MaxTotalTokens        int    `json:"max_total_tokens"`
+	MaxBatchTotalTokens   int    `json:"max_batch_total_tokens"`
+	MaxConcurrentRequests int    `json:"max_concurrent_requests"`
+}
+
+func detectTgi(ctx context.Context, url, _ string) (*Info, error) {
+	body, err := http.Get(ctx, url+"/info")
+	if err != nil {
+		return nil, err
+	}
+	var i tgiInfo
+	if err = json.Unmarshal(body, &i); err != nil {
+		return nil, err
+	}
+	if i.ModelId == "" {
+		return nil, errors.New("/info does not contain model_id")
+	}
+	limits := make(map[string]int)
+	addLimit(limits, "max_input_tokens", max(i.MaxInputTokens, i.MaxInputLength))
+	addLimit(limits, "max_batch_total_tokens", i.MaxBatchTotalTokens)
+	addLimit(limits, "max_concurrent_requests", i.MaxConcurrentRequests)
+	model := &Model{Name: i.ModelId, MaxContext: i.MaxTotalTokens, Limits: limits}
+	return &Info{Version: i.Version, Models: []*Model{model}}, nil
+}
+
+// tritonModelConfig triton /v2/models/{name}/config 接口的返回中用到的字段
+type tritonModelConfig struct {
+	MaxBatchSize int `json:"max_batch_size"`
+	Parameters   map[string]struct {
+		StringValue string `json:"string_value"`
+	} `json:"parameters"`
+}
+
+// tritonContextKeys TensorRT-LLM 模型配置中表示上下文长度的参数，按优先级排列
+var tritonContextKeys = []string{"max_seq_len", "max_sequence_length", "max_attention_window_size"}
+
+func detectTriton(ctx context.Context, url, model string) (*Info, error) {
+	if _, err := http.Get(ctx, url+"/v2/health/ready"); err != nil {
+		return nil, err
+	}
+	info := &Info{}
+	var server struct {
+		Version string `json:"version"`
+	}
+	if body, err := http.Get(ctx, url+"/v2"); err == nil && json.Unmarshal(body, &server) == nil {
+		info.Version = server.Version
+	}
+	names, err := tritonModels(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		m := &Model{Name: name, Limits: make(map[string]int)}
+		info.Models = append(info.Models, m)
+		if model != "" && name != model {
+			continue
+		}
+		body, err := http.Get(ctx, fmt.Sprintf("%s/v2/models/%s/config", url, name))
+		if err != nil {
+			continue
+		}
+		var c tritonModelConfig
+		if json.Unmarshal(body, &c) != nil {
+			continue
+		}
+		addLimit(m.Limits, "max_batch_size", c.MaxBatchSize)
+		for key, p := range c.Parameters {
+			if v, err := strconv.Atoi(p.StringValue); err == nil && strings.HasPrefix(key, "max_") {
+				addLimit(m.Limits, key, v)
+			}
+		}
+		for _, key := range tritonContextKeys {
+			if v, ok := m.Limits[key]; ok {
+				m.MaxContext = v
+				break
+			}
+		}
+	}
+	return info, nil
+}
+
+// tritonModels 返回模型仓库中已加载的模型
+func tritonModels(ctx context.Context, url string) ([]string, error) {
+	body, err := http.Post(ctx, url+"/v2/repository/index", map[string]bool{"ready": true})
+	if err != nil {
+		return nil, err
+	}
+	var models []struct {
+		Name string `json:"name"`
+	}
+	if err = json.Unmarshal(body, &models); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, m := range models {
+		names = append(names, m.Name)
+	}
+	return names, nil
+}
+
+// openaiModels OpenAI 兼容的 /v1/models 接口的返回，max_model_len 和 parent 为 vllm 特有的字段
+type openaiModels struct {
+	Data []struct {
+		Id          string `json:"id"`
+		MaxModelLen int    `json:"max_model_len"`
+		Parent      string `json:"parent"`
+	} `json:"data"`
+}
+
+func detectVllm(ctx context.Context, url, _ string) (*Info, error) {
+	body, err := http.Get(ctx, url+"/v1/models")
+	if err != nil {
+		return nil, err
+	}
+	var models openaiModels
+	if err = json.Unmarshal(body, &models); err != nil {
+		return nil, err
+	}
+	if len(models.Data) == 0 {
+		return nil, errors.New("/v1/models returns no model")
+	}
+	info := &Info{}
+	for _, m := range models.Data {
+		info.Models = append(info.Models, &Model{Name: m.Id, Parent: m.Parent, MaxContext: m.MaxModelLen})
+	}
+	var version struct {
+		Version string `json:"version"`
+	}
+	if body, err := http.Get(ctx, url+"/version"); err == nil && json.Unmarshal(body, &version) == nil {
+		info.Version = version.Version
+	}
+	return info, nil
+}
+
+func addLimit(limits map[string]int, key string, v int) {
+	if v > 0 {
+		limits[key] = v
+	}
+}
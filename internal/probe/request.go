@@ -0,0 +1,66 @@
+package probe
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/infer"
+	"github.com/nullxjx/llm_profiler/internal/infer/param"
+	"github.com/nullxjx/llm_profiler/internal/prompt"
+)
+
+// testPrompt 测试请求使用的输入
+const testPrompt = "Hello, please introduce yourself."
+
+// ModeResult 以一种方式发送测试请求的结果
+type ModeResult struct {
+	Stream         bool
+	Ok             bool    // 请求是否成功，失败原因见日志
+	TimeSpent      int64   // 耗时，毫秒
+	FirstTokenTime float64 // 首token时间，仅在流式场景下存在
+	OutputTokens   int
+	Output         string
+}
+
+// TestModes 使用压测时的请求方式，以非流式和流式（后端支持时）分别发送一条测试请求
+func TestModes(ctx context.Context, cfg *config.Config) []*ModeResult {
+	p := prompt.FromMessages([]prompt.Message{{Role: prompt.RoleUser, Content: testPrompt}})
+	var results []*ModeResult
+	for _, stream := range []bool{false, true} {
+		handler, ok := infer.Handler(cfg.Backend, stream)
+		if !ok {
+			continue
+		}
+		c := *cfg
+		c.Stream = stream
+		results = append(results, send(ctx, &c, p, handler))
+	}
+	return results
+}
+
+// send 同步发送一条请求，请求失败时发送函数不会返回结果
+func send(ctx context.Context, cfg *config.Config, p *prompt.Prompt, handler func(*param.RequestParam)) *ModeResult {
+	results := make(chan param.Result, 1)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	handler(&param.RequestParam{
+		Ctx:     ctx,
+		Wg:      wg,
+		Result:  results,
+		Prompt:  p,
+		Counter: &param.Counter{},
+		Config:  cfg,
+	})
+	r := &ModeResult{Stream: cfg.Stream}
+	select {
+	case res := <-results:
+		r.Ok = true
+		r.TimeSpent = res.TimeSpent
+		r.FirstTokenTime = res.FirstTokenTime
+		r.OutputTokens = res.OutputTokens
+		r.Output = res.Output
+	default:
+	}
+	return r
+}
@@ -0,0 +1,52 @@
+package prompt
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/dataset"
+)
+
+// maxConversations 最多读取的多轮对话数
+const maxConversations = 10000
+
+var errEnough = errors.New("enough conversations")
+
+// LoadConversations 读取多轮对话负载中每个会话的用户消息，没有配置对话文件时随机生成
+func LoadConversations(cfg *config.Config) ([][]string, error) {
+	s := &cfg.Session
+	if s.Path == "" {
+		g, err := NewGenerator(cfg)
+		if err != nil {
+			return nil, err
+		}
+		r := rand.New(rand.NewSource(int64(s.Turns)))
+		conversations := make([][]string, cfg.Prompt.GetCount())
+		for i := range conversations {
+			conversations[i] = make([]string, s.Turns)
+			for j := range conversations[i] {
+				if conversations[i][j], err = g.Generate(r, s.GetUserTokens()); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return conversations, nil
+	}
+
+	var conversations [][]string
+	err := dataset.ReadConversations(s.Path, func(turns []string) error {
+		conversations = append(conversations, turns)
+		if len(conversations) >= maxConversations {
+			return errEnough
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errEnough) {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return nil, errors.New("no conversations in " + s.Path)
+	}
+	return conversations, nil
+}
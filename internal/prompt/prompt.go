@@ -0,0 +1,110 @@
+package prompt
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nullxjx/llm_profiler/config"
+)
+
+// 对话消息的角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message 对话消息
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Prompt 一条测试输入，Text 用于补全接口，Messages 用于对话接口
+type Prompt struct {
+	Text         string    // 补全接口的输入
+	Messages     []Message // 对话接口的输入，包含系统提示
+	Tokens       int       // 数据集中记录的输入token数，0 表示未知
+	OutputTokens int       // 期望的输出token数，0 表示未知
+}
+
+// Source prompt 来源
+type Source interface {
+	// Load 读取所有 prompt，此时还没有添加前缀和系统提示
+	Load(cfg *config.Config) ([]*Prompt, error)
+}
+
+// sources 所有支持的 prompt 来源
+var sources = map[string]Source{
+	config.PromptSourceBuckets:   bucketSource{},
+	config.PromptSourceFile:      fileSource{},
+	config.PromptSourceChat:      chatSource{},
+	config.PromptSourceSynthetic: syntheticSource{},
+}
+
+// Supported 判断是否支持给定的 prompt 来源
+func Supported(source string) bool {
+	_, ok := sources[source]
+	return ok
+}
+
+// Load 根据配置读取 prompt，添加配置的前缀和系统提示
+func Load(cfg *config.Config) ([]*Prompt, error) {
+	name := cfg.Prompt.GetSource()
+	source, ok := sources[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported prompt source: %s", name)
+	}
+	prompts, err := source.Load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("no prompts loaded from %s source", name)
+	}
+	prefix := cfg.Prompt.GetPrefix()
+	system := cfg.Prompt.GetSystemPrompt()
+	for _, p := range prompts {
+		if p.Messages == nil {
+			p.Text = prefix + p.Text
+			p.Messages = []Message{{Role: RoleUser, Content: p.Text}}
+		} else {
+			// 对话数据没有单独的文本，补全接口使用拼接后的对话
+			p.Text = Flatten(p.Messages)
+		}
+		if system != "" && p.Messages[0].Role != RoleSystem {
+			p.Messages = append([]Message{{Role: RoleSystem, Content: system}}, p.Messages...)
+		}
+	}
+	return prompts, nil
+}
+
+// WithPrefix 返回在最前面添加了 prefix 的 prompt，对话接口的 prefix 添加在第一条消息中
+func WithPrefix(p *Prompt, prefix string) *Prompt {
+	c := *p
+	c.Text = prefix + p.Text
+	if len(p.Messages) > 0 {
+		c.Messages = slices.Clone(p.Messages)
+		c.Messages[0].Content = prefix + c.Messages[0].Content
+	}
+	return &c
+}
+
+// FromMessages 使用一组对话消息创建 prompt
+func FromMessages(messages []Message) *Prompt {
+	return &Prompt{Text: Flatten(messages), Messages: messages}
+}
+
+// Flatten 把对话拼接为补全接口的输入
+func Flatten(messages []Message) string {
+	var sb strings.Builder
+	for _, m := range messages {
+		sb.WriteString(m.Role)
+		sb.WriteString(": ")
+		sb.WriteString(m.Content)
+		sb.WriteString("\n")
+	}
+	sb.WriteString(RoleAssistant + ": ")
+	return sb.String()
+}
@@ -0,0 +1,166 @@
+package prompt
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/utils"
+)
+
+// bucketSource 按输入token数分组的数据集，读取 inputTokens 对应的分组文件
+type bucketSource struct{}
+
+func (bucketSource) Load(cfg *config.Config) ([]*Prompt, error) {
+	file, err := os.Open(cfg.Prompt.BucketPath(cfg.InputTokens))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var inputs []utils.Input
+	if err = json.NewDecoder(file).Decode(&inputs); err != nil {
+		return nil, err
+	}
+	prompts := make([]*Prompt, 0, len(inputs))
+	for _, input := range inputs {
+		prompts = append(prompts, &Prompt{
+			Text:         input.Prompt,
+			Tokens:       input.Tokens,
+			OutputTokens: input.OutputTokens,
+		})
+	}
+	return prompts, nil
+}
+
+// fileSource 自定义的 jsonl 或 csv 文件，按扩展名区分
+// jsonl 每行为 {"prompt": "...", "output_tokens": 128}，csv 第一行为表头，包含 prompt 列和可选的 output_tokens 列
+type fileSource struct{}
+
+type fileItem struct {
+	Prompt       string `json:"prompt"`
+	OutputTokens int    `json:"output_tokens"`
+}
+
+func (fileSource) Load(cfg *config.Config) ([]*Prompt, error) {
+	path := cfg.Prompt.Path
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return readCsv(path)
+	}
+	var prompts []*Prompt
+	err := readJsonl(path, func(data []byte) error {
+		var item fileItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			return err
+		}
+		if item.Prompt == "" {
+			return fmt.Errorf("empty prompt")
+		}
+		prompts = append(prompts, &Prompt{Text: item.Prompt, OutputTokens: item.OutputTokens})
+		return nil
+	})
+	return prompts, err
+}
+
+func readCsv(path string) ([]*Prompt, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header error: %v", err)
+	}
+	promptCol, outputCol := -1, -1
+	for i, name := range header {
+		switch strings.TrimSpace(strings.ToLower(name)) {
+		case "prompt":
+			promptCol = i
+		case "output_tokens":
+			outputCol = i
+		}
+	}
+	if promptCol < 0 {
+		return nil, fmt.Errorf("no prompt column in csv header %v", header)
+	}
+
+	var prompts []*Prompt
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		p := &Prompt{Text: record[promptCol]}
+		if outputCol >= 0 && record[outputCol] != "" {
+			if p.OutputTokens, err = strconv.Atoi(record[outputCol]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid output_tokens %q", line, record[outputCol])
+			}
+		}
+		prompts = append(prompts, p)
+	}
+	return prompts, nil
+}
+
+// chatSource 对话数据，jsonl 每行为一组消息 [{"role": "user", "content": "..."}]，
+// 或者 {"messages": [...], "output_tokens": 128}
+type chatSource struct{}
+
+type chatItem struct {
+	Messages     []Message `json:"messages"`
+	OutputTokens int       `json:"output_tokens"`
+}
+
+func (chatSource) Load(cfg *config.Config) ([]*Prompt, error) {
+	var prompts []*Prompt
+	err := readJsonl(cfg.Prompt.Path, func(data []byte) error {
+		var item chatItem
+		if strings.HasPrefix(string(data), "[") {
+			if err := json.Unmarshal(data, &item.Messages); err != nil {
+				return err
+			}
+		} else if err := json.Unmarshal(data, &item); err != nil {
+			return err
+		}
+		if len(item.Messages) == 0 {
+			return fmt.Errorf("empty messages")
+		}
+		prompts = append(prompts, &Prompt{Messages: item.Messages, OutputTokens: item.OutputTokens})
+		return nil
+	})
+	return prompts, err
+}
+
+// readJsonl 逐行读取 jsonl 文件，跳过空行
+func readJsonl(path string, fn func([]byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		data := []byte(strings.TrimSpace(scanner.Text()))
+		if len(data) == 0 {
+			continue
+		}
+		if err := fn(data); err != nil {
+			return fmt.Errorf("%s line %d: %v", path, line, err)
+		}
+	}
+	return scanner.Err()
+}
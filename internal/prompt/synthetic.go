@@ -0,0 +1,122 @@
+package prompt
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/tokenizer"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxSyntheticTokens 随机生成的 prompt 的token总数上限，超长的 prompt 会减少生成的数量，避免占用过多内存
+const maxSyntheticTokens = 8 << 20
+
+// syntheticSource 随机生成由常见英文单词组成的 prompt，配置了分词器时 token 数与 inputTokens 完全相同，
+// 否则单词数与 inputTokens 相同，大部分分词器中每个单词约为一个token
+type syntheticSource struct{}
+
+var words = strings.Fields(`the of and to in is was for that on as with by he at from his an were are which
+this be or had not but first one their its new after who they have her she two been other when there all during
+into school time may years more most only over city some world would where later up such used many can state
+about national out known university united then made`)
+
+func (syntheticSource) Load(cfg *config.Config) ([]*Prompt, error) {
+	g, err := NewGenerator(cfg)
+	if err != nil {
+		return nil, err
+	}
+	count := cfg.Prompt.GetCount()
+	if limit := max(1, maxSyntheticTokens/cfg.InputTokens); count > limit {
+		log.Warnf("Generate %d instead of %d synthetic prompts with %d tokens to limit memory usage",
+			limit, count, cfg.InputTokens)
+		count = limit
+	}
+	r := rand.New(rand.NewSource(int64(cfg.InputTokens)))
+	// Load 会在 prompt 前面添加前缀，配置了分词器时添加前缀后恰好为 inputTokens 个token
+	prefix := cfg.Prompt.GetPrefix()
+	prompts := make([]*Prompt, count)
+	for i := range prompts {
+		text, err := g.GenerateAfter(r, cfg.InputTokens, prefix)
+		if err != nil {
+			return nil, err
+		}
+		prompts[i] = &Prompt{Text: text}
+		if g.tok != nil {
+			prompts[i].Tokens = cfg.InputTokens
+		}
+	}
+	return prompts, nil
+}
+
+// Synthetic 随机生成由 n 个常见英文单词组成的文本
+func Synthetic(r *rand.Rand, n int) string {
+	text := make([]string, n)
+	for i := range text {
+		text[i] = words[r.Intn(len(words))]
+	}
+	return strings.Join(text, " ")
+}
+
+// Generator 随机生成给定token数的文本，配置了分词器时token数精确，否则按单词数近似
+type Generator struct {
+	tok *tokenizer.Tokenizer
+}
+
+// NewGenerator 根据配置中的 prompt.tokenizer 创建文本生成器
+func NewGenerator(cfg *config.Config) (*Generator, error) {
+	if cfg.Prompt.Tokenizer == "" {
+		return &Generator{}, nil
+	}
+	tok, err := tokenizer.LoadCached(cfg.Prompt.Tokenizer)
+	if err != nil {
+		return nil, fmt.Errorf("load tokenizer %s error: %v", cfg.Prompt.Tokenizer, err)
+	}
+	return &Generator{tok: tok}, nil
+}
+
+// Generate 随机生成约 n 个token的文本，配置了分词器时恰好为 n 个token，不包含 bos 和对话模板添加的token
+func (g *Generator) Generate(r *rand.Rand, n int) (string, error) {
+	return g.GenerateAfter(r, n, "")
+}
+
+// GenerateAfter 随机生成添加在 prefix 之后的文本，配置了分词器时 prefix 加上生成的文本恰好为 n 个token，
+// 返回的文本不包含 prefix。没有分词器时生成约 n 个token的文本，不考虑 prefix
+func (g *Generator) GenerateAfter(r *rand.Rand, n int, prefix string) (string, error) {
+	if g.tok == nil {
+		return Synthetic(r, n), nil
+	}
+	if p := g.tok.Count(prefix); p >= n {
+		return "", fmt.Errorf("prefix has %d tokens, can not generate text with exactly %d tokens", p, n)
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		if text, ok := g.exact(r, n, prefix); ok {
+			return text, nil
+		}
+	}
+	return "", fmt.Errorf("can not generate text with exactly %d tokens", n)
+}
+
+// exact 先由常见单词组成足够长的文本，按token截断后解码。截断处的单词解码后可能被重新切分，
+// prefix 与文本相接处也可能合并为一个token，根据加上 prefix 重新编码后的token数调整截断位置
+func (g *Generator) exact(r *rand.Rand, n int, prefix string) (string, bool) {
+	var ids []int
+	for count := n + 16; len(ids) <= n; count *= 2 {
+		if count > 16*(n+16) { // 分词器无法编码这些单词
+			return "", false
+		}
+		ids = g.tok.Encode(Synthetic(r, count))
+	}
+	cut := n - g.tok.Count(prefix)
+	for i := 0; i < 16 && cut > 0 && cut <= len(ids); i++ {
+		text := g.tok.Decode(ids[:cut])
+		c := g.tok.Count(prefix + text)
+		if c == n {
+			return text, true
+		}
+		cut += n - c
+	}
+	return "", false
+}
@@ -0,0 +1,139 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+)
+
+const (
+	chartWidth   = 640
+	chartHeight  = 360
+	marginLeft   = 64
+	marginRight  = 16
+	marginTop    = 16
+	marginBottom = 48
+	tickCount    = 5
+)
+
+var palette = []string{"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f", "#edc948", "#b07aa1", "#ff9da7"}
+
+// Point 图表中的一个点
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Series 图表中的一条曲线或一组散点
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+// Chart 一张二维图表，渲染为内联 SVG，点击图例可以隐藏或显示对应的曲线
+type Chart struct {
+	Title   string
+	XLabel  string
+	YLabel  string
+	Series  []Series
+	Scatter bool // 为 true 时只画点不画线
+}
+
+// SVG 把图表渲染为内联 SVG
+func (c *Chart) SVG() template.HTML {
+	minX, maxX, maxY := c.bounds()
+	plotW := float64(chartWidth - marginLeft - marginRight)
+	plotH := float64(chartHeight - marginTop - marginBottom)
+	sx := func(x float64) float64 { return marginLeft + (x-minX)/(maxX-minX)*plotW }
+	sy := func(y float64) float64 { return marginTop + plotH - y/maxY*plotH }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`,
+		chartWidth, chartHeight)
+	// 坐标轴和刻度
+	for i := 0; i <= tickCount; i++ {
+		y := maxY * float64(i) / tickCount
+		fmt.Fprintf(&b, `<line class="grid" x1="%d" x2="%d" y1="%.1f" y2="%.1f"/>`,
+			marginLeft, chartWidth-marginRight, sy(y), sy(y))
+		fmt.Fprintf(&b, `<text class="tick" x="%d" y="%.1f" text-anchor="end">%s</text>`,
+			marginLeft-6, sy(y)+4, formatTick(y))
+		x := minX + (maxX-minX)*float64(i)/tickCount
+		fmt.Fprintf(&b, `<text class="tick" x="%.1f" y="%d" text-anchor="middle">%s</text>`,
+			sx(x), chartHeight-marginBottom+16, formatTick(x))
+	}
+	fmt.Fprintf(&b, `<text class="label" x="%.1f" y="%d" text-anchor="middle">%s</text>`,
+		marginLeft+plotW/2, chartHeight-8, template.HTMLEscapeString(c.XLabel))
+	fmt.Fprintf(&b, `<text class="label" transform="translate(14,%.1f) rotate(-90)" text-anchor="middle">%s</text>`,
+		marginTop+plotH/2, template.HTMLEscapeString(c.YLabel))
+
+	for i, series := range c.Series {
+		color := palette[i%len(palette)]
+		fmt.Fprintf(&b, `<g class="series" data-series="%d" fill="%s" stroke="%s">`, i, color, color)
+		if !c.Scatter && len(series.Points) > 1 {
+			var path strings.Builder
+			for j, p := range series.Points {
+				cmd := "L"
+				if j == 0 {
+					cmd = "M"
+				}
+				fmt.Fprintf(&path, "%s%.1f,%.1f ", cmd, sx(p.X), sy(p.Y))
+			}
+			fmt.Fprintf(&b, `<path fill="none" stroke-width="2" d="%s"/>`, strings.TrimSpace(path.String()))
+		}
+		radius, opacity := 3.5, 1.0
+		if c.Scatter {
+			radius, opacity = 2, 0.5
+		}
+		for _, p := range series.Points {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="%.1f" stroke="none" fill-opacity="%.1f">`+
+				`<title>%s: x=%s, y=%s</title></circle>`,
+				sx(p.X), sy(p.Y), radius, opacity, template.HTMLEscapeString(series.Name),
+				formatTick(p.X), formatTick(p.Y))
+		}
+		b.WriteString(`</g>`)
+	}
+	b.WriteString(`</svg>`)
+
+	// 图例
+	b.WriteString(`<div class="legend">`)
+	for i, series := range c.Series {
+		fmt.Fprintf(&b, `<span class="legend-item" data-series="%d"><i style="background:%s"></i>%s</span>`,
+			i, palette[i%len(palette)], template.HTMLEscapeString(series.Name))
+	}
+	b.WriteString(`</div>`)
+	return template.HTML(b.String())
+}
+
+// bounds 计算坐标轴范围，y 轴从 0 开始
+func (c *Chart) bounds() (float64, float64, float64) {
+	minX, maxX, maxY := math.MaxFloat64, -math.MaxFloat64, 0.0
+	for _, series := range c.Series {
+		for _, p := range series.Points {
+			minX = math.Min(minX, p.X)
+			maxX = math.Max(maxX, p.X)
+			maxY = math.Max(maxY, p.Y)
+		}
+	}
+	if minX == math.MaxFloat64 {
+		minX, maxX = 0, 1
+	}
+	if maxX == minX {
+		minX, maxX = minX-1, maxX+1
+	}
+	if maxY == 0 {
+		maxY = 1
+	}
+	return minX, maxX, maxY * 1.05
+}
+
+func formatTick(v float64) string {
+	switch {
+	case math.Abs(v) >= 1000:
+		return fmt.Sprintf("%.0f", v)
+	case math.Abs(v) >= 10:
+		return fmt.Sprintf("%.1f", v)
+	default:
+		return fmt.Sprintf("%.2f", v)
+	}
+}
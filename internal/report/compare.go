@@ -0,0 +1,238 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/nullxjx/llm_profiler/internal/infer/param"
+	"github.com/nullxjx/llm_profiler/internal/perf/throughput"
+	"github.com/nullxjx/llm_profiler/internal/utils"
+)
+
+// Thresholds 判断性能退化的阈值
+type Thresholds struct {
+	Throughput  float64 // 吞吐量下降超过该百分比视为退化
+	Latency     float64 // 延迟上升超过该百分比视为退化
+	FirstToken  float64 // 首token时间上升超过该百分比视为退化
+	SuccessRate float64 // 成功率下降超过该百分点视为退化
+	Alpha       float64 // 显著性水平，有单条请求结果时，p 值小于该值的变化才视为退化
+}
+
+// Delta 某一并发度下某个指标的变化
+type Delta struct {
+	Concurrency int
+	Metric      string
+	Base        float64
+	Candidate   float64
+	Diff        float64
+	Percent     float64 // 相对基线的变化百分比，成功率为百分点
+	PValue      float64 // 显著性检验的 p 值，没有单条请求结果时为 NaN
+	Regression  bool
+}
+
+// Comparison 一次运行相对基线运行的对比结果
+type Comparison struct {
+	Baseline  string
+	Candidate string
+	Deltas    []Delta
+	Missing   []int // 只在其中一次运行中出现的并发度
+}
+
+// metric 参与对比的指标
+type metric struct {
+	name           string
+	higherIsBetter bool
+	percentPoint   bool                                        // 为 true 时按百分点计算变化
+	threshold      func(*Thresholds) float64                   // 退化阈值
+	value          func(*throughput.StatisticsSummary) float64 // 从统计结果中取值
+	samples        func(param.Result) (float64, bool)          // 从单条请求结果中取样本，用于显著性检验
+	test           func(a, b []float64) float64                // 显著性检验，为 nil 时对均值做 Welch t 检验
+}
+
+var metrics = []metric{
+	{
+		name: "output tokens/s", higherIsBetter: true,
+		threshold: func(t *Thresholds) float64 { return t.Throughput },
+		value:     func(s *throughput.StatisticsSummary) float64 { return s.ServerOutputTokensPerSecond },
+	},
+	{
+		name: "req/s", higherIsBetter: true,
+		threshold: func(t *Thresholds) float64 { return t.Throughput },
+		value:     func(s *throughput.StatisticsSummary) float64 { return s.RequestPerSecond },
+	},
+	{
+		name:      "avg latency ms",
+		threshold: func(t *Thresholds) float64 { return t.Latency },
+		value:     func(s *throughput.StatisticsSummary) float64 { return s.AvgTimeClientSide },
+		samples:   latencySample,
+	},
+	{
+		name:      "P90 ms",
+		threshold: func(t *Thresholds) float64 { return t.Latency },
+		value:     func(s *throughput.StatisticsSummary) float64 { return s.P90 },
+		samples:   latencySample,
+		test:      percentileTest(90),
+	},
+	{
+		name:      "P99 ms",
+		threshold: func(t *Thresholds) float64 { return t.Latency },
+		value:     func(s *throughput.StatisticsSummary) float64 { return s.P99 },
+		samples:   latencySample,
+		test:      percentileTest(99),
+	},
+	{
+		name:      "first token ms",
+		threshold: func(t *Thresholds) float64 { return t.FirstToken },
+		value:     func(s *throughput.StatisticsSummary) float64 { return s.FirstTokenTime },
+		samples: func(r param.Result) (float64, bool) {
+			return r.FirstTokenTime, r.FirstTokenTime > 0
+		},
+	},
+	{
+		name: "success rate %", higherIsBetter: true, percentPoint: true,
+		threshold: func(t *Thresholds) float64 { return t.SuccessRate },
+		value: func(s *throughput.StatisticsSummary) float64 {
+			if s.Total == 0 {
+				return 0
+			}
+			return float64(s.Success) / float64(s.Total) * 100
+		},
+	},
+}
+
+func latencySample(r param.Result) (float64, bool) {
+	return float64(r.TimeSpent), true
+}
+
+// percentileTest 分位数指标的显著性检验，均值的 t 检验无法检出只发生在尾部的变化
+func percentileTest(percent float64) func(a, b []float64) float64 {
+	return func(a, b []float64) float64 {
+		return utils.BootstrapPercentileTest(a, b, percent)
+	}
+}
+
+// Compare 按并发度对齐两次运行的每一轮，计算各项指标的变化并判断是否退化
+func Compare(base, candidate *Run, th *Thresholds) *Comparison {
+	c := &Comparison{Baseline: base.Dir, Candidate: candidate.Dir}
+	baseRounds := roundsByConcurrency(base)
+	candidateRounds := roundsByConcurrency(candidate)
+	for concurrency := range candidateRounds {
+		if _, ok := baseRounds[concurrency]; !ok {
+			c.Missing = append(c.Missing, concurrency)
+		}
+	}
+	for _, b := range base.Rounds {
+		cr, ok := candidateRounds[b.Concurrency]
+		if !ok {
+			c.Missing = append(c.Missing, b.Concurrency)
+			continue
+		}
+		for _, m := range metrics {
+			c.Deltas = append(c.Deltas, compareMetric(&m, th, b, cr,
+				base.Requests[b.Concurrency], candidate.Requests[b.Concurrency]))
+		}
+	}
+	sort.Ints(c.Missing)
+	return c
+}
+
+func compareMetric(m *metric, th *Thresholds, base, candidate *throughput.StatisticsSummary,
+	baseResults, candidateResults []param.Result) Delta {
+	d := Delta{
+		Concurrency: base.Concurrency,
+		Metric:      m.name,
+		Base:        m.value(base),
+		Candidate:   m.value(candidate),
+		PValue:      math.NaN(),
+	}
+	d.Diff = d.Candidate - d.Base
+	switch {
+	case m.percentPoint:
+		d.Percent = d.Diff
+	case d.Base != 0:
+		d.Percent = d.Diff / d.Base * 100
+	}
+	if d.Base == 0 && !m.percentPoint {
+		// 基线没有该指标（例如非流式场景下的首token时间），不做判断
+		return d
+	}
+
+	worse := d.Percent
+	if m.higherIsBetter {
+		worse = -d.Percent
+	}
+	d.Regression = worse > m.threshold(th)
+	if m.samples != nil {
+		a, b := collect(baseResults, m.samples), collect(candidateResults, m.samples)
+		if len(a) >= utils.MinimumCount && len(b) >= utils.MinimumCount {
+			test := utils.WelchTTest
+			if m.test != nil {
+				test = m.test
+			}
+			d.PValue = test(a, b)
+			// 有样本时只有统计显著的变化才算退化
+			d.Regression = d.Regression && d.PValue < th.Alpha
+		}
+	}
+	return d
+}
+
+func collect(results []param.Result, sample func(param.Result) (float64, bool)) []float64 {
+	var values []float64
+	for _, r := range results {
+		if v, ok := sample(r); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func roundsByConcurrency(run *Run) map[int]*throughput.StatisticsSummary {
+	rounds := make(map[int]*throughput.StatisticsSummary)
+	for _, r := range run.Rounds {
+		rounds[r.Concurrency] = r
+	}
+	return rounds
+}
+
+// Regressions 返回退化的指标
+func (c *Comparison) Regressions() []Delta {
+	var res []Delta
+	for _, d := range c.Deltas {
+		if d.Regression {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// Print 以表格形式输出对比结果，退化的指标会被标记出来
+func (c *Comparison) Print(w io.Writer) {
+	fmt.Fprintf(w, "baseline:  %s\ncandidate: %s\n\n", c.Baseline, c.Candidate)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "concurrency\tmetric\tbaseline\tcandidate\tdelta\tchange\tp-value\t\t")
+	for _, d := range c.Deltas {
+		change := fmt.Sprintf("%+.1f%%", d.Percent)
+		if d.Metric == "success rate %" {
+			change = fmt.Sprintf("%+.1fpp", d.Percent)
+		}
+		pValue := "-"
+		if !math.IsNaN(d.PValue) {
+			pValue = fmt.Sprintf("%.3f", d.PValue)
+		}
+		flag := ""
+		if d.Regression {
+			flag = "❗️REGRESSION"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%.1f\t%.1f\t%+.1f\t%s\t%s\t%s\t\n",
+			d.Concurrency, d.Metric, d.Base, d.Candidate, d.Diff, change, pValue, flag)
+	}
+	tw.Flush()
+	if len(c.Missing) > 0 {
+		fmt.Fprintf(w, "\nconcurrency only tested in one run: %v\n", c.Missing)
+	}
+	fmt.Fprintf(w, "\n%d regressions found\n", len(c.Regressions()))
+}
@@ -0,0 +1,229 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nullxjx/llm_profiler/internal/infer/param"
+
+	"github.com/montanaflynn/stats"
+)
+
+// maxScatterPoints 每一轮在散点图中最多展示的请求数，避免报告文件过大
+const maxScatterPoints = 500
+
+const concurrencyLabel = "Concurrency (reqs / duration)"
+
+// page 报告页面渲染所需的数据
+type page struct {
+	Title       string
+	GeneratedAt string
+	Run         *Run
+	Charts      []*Chart
+	Config      string
+}
+
+// WriteHTML 把压测结果渲染为一个不依赖外部资源的 html 文件
+func WriteHTML(run *Run, path string) error {
+	p := &page{
+		Title:       fmt.Sprintf("LLM-Profiler report: %s", filepath.Base(run.Dir)),
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Run:         run,
+		Charts:      buildCharts(run),
+	}
+	if run.Config != nil {
+		data, err := json.MarshalIndent(run.Config, "", "  ")
+		if err != nil {
+			return err
+		}
+		p.Config = string(data)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return pageTemplate.Execute(file, p)
+}
+
+func buildCharts(run *Run) []*Chart {
+	var outputTokens, inputTokens, requests, targetRate, achievedRate, successRate []Point
+	var p80, p90, p99, avgLatency, ttft, ttftP90, tpot, tpotP90, clientSpeed []Point
+	var scatter []Point
+	for _, r := range run.Rounds {
+		x := float64(r.Concurrency)
+		outputTokens = append(outputTokens, Point{x, r.ServerOutputTokensPerSecond})
+		inputTokens = append(inputTokens, Point{x, r.ServerInputTokensPerSecond})
+		requests = append(requests, Point{x, r.RequestPerSecond})
+		if r.TargetRate > 0 {
+			targetRate = append(targetRate, Point{x, r.TargetRate})
+			achievedRate = append(achievedRate, Point{x, r.AchievedRate})
+		}
+		if r.Total > 0 {
+			successRate = append(successRate, Point{x, float64(r.Success) / float64(r.Total) * 100})
+		}
+		p80 = append(p80, Point{x, r.P80})
+		p90 = append(p90, Point{x, r.P90})
+		p99 = append(p99, Point{x, r.P99})
+		avgLatency = append(avgLatency, Point{x, r.AvgTimeClientSide})
+		if r.FirstTokenTime > 0 {
+			ttft = append(ttft, Point{x, r.FirstTokenTime})
+		}
+		if r.ClientOutputTokensPerSecond > 0 {
+			clientSpeed = append(clientSpeed, Point{x, r.ClientOutputTokensPerSecond})
+		}
+
+		results := run.Requests[r.Concurrency]
+		ttfts, tpots := streamLatencies(results)
+		if len(ttfts) > 0 {
+			v, _ := stats.Percentile(ttfts, 90)
+			ttftP90 = append(ttftP90, Point{x, v})
+		}
+		if len(tpots) > 0 {
+			mean, _ := stats.Mean(tpots)
+			v, _ := stats.Percentile(tpots, 90)
+			tpot = append(tpot, Point{x, mean})
+			tpotP90 = append(tpotP90, Point{x, v})
+		}
+		step := len(results)/maxScatterPoints + 1
+		for i := 0; i < len(results); i += step {
+			scatter = append(scatter, Point{x, float64(results[i].TimeSpent)})
+		}
+	}
+
+	charts := []*Chart{
+		{
+			Title: "Server throughput", XLabel: concurrencyLabel, YLabel: "tokens/s",
+			Series: []Series{{"output tokens/s", outputTokens}, {"input tokens/s", inputTokens}},
+		},
+		{
+			Title: "Request rate", XLabel: concurrencyLabel, YLabel: "req/s",
+			Series: nonEmpty(Series{"completed req/s", requests}, Series{"target send rate", targetRate},
+				Series{"achieved send rate", achievedRate}),
+		},
+		{
+			Title: "Latency percentiles", XLabel: concurrencyLabel, YLabel: "ms",
+			Series: []Series{{"avg", avgLatency}, {"P80", p80}, {"P90", p90}, {"P99", p99}},
+		},
+		{
+			Title: "Success rate", XLabel: concurrencyLabel, YLabel: "%",
+			Series: []Series{{"success rate", successRate}},
+		},
+	}
+	if len(ttft) > 0 || len(ttftP90) > 0 {
+		charts = append(charts, &Chart{
+			Title: "Time to first token (TTFT)", XLabel: concurrencyLabel, YLabel: "ms",
+			Series: nonEmpty(Series{"avg", ttft}, Series{"P90", ttftP90}),
+		})
+	}
+	if len(tpot) > 0 {
+		charts = append(charts, &Chart{
+			Title: "Time per output token (TPOT)", XLabel: concurrencyLabel, YLabel: "ms",
+			Series: []Series{{"avg", tpot}, {"P90", tpotP90}},
+		})
+	}
+	if len(clientSpeed) > 0 {
+		charts = append(charts, &Chart{
+			Title: "Client stream speed", XLabel: concurrencyLabel, YLabel: "tokens/s",
+			Series: []Series{{"client tokens/s", clientSpeed}},
+		})
+	}
+	if len(scatter) > 0 {
+		charts = append(charts, &Chart{
+			Title: "Per-request latency", XLabel: concurrencyLabel, YLabel: "ms", Scatter: true,
+			Series: []Series{{"request latency", scatter}},
+		})
+	}
+	return charts
+}
+
+// streamLatencies 从流式请求结果中提取首token时间和每个输出token的平均耗时，单位毫秒
+func streamLatencies(results []param.Result) (stats.Float64Data, stats.Float64Data) {
+	var ttfts, tpots stats.Float64Data
+	for _, r := range results {
+		if r.FirstTokenTime <= 0 {
+			continue
+		}
+		ttfts = append(ttfts, r.FirstTokenTime)
+		if r.OutputTokens > 1 {
+			tpots = append(tpots, (float64(r.TimeSpent)-r.FirstTokenTime)/float64(r.OutputTokens-1))
+		}
+	}
+	return ttfts, tpots
+}
+
+func nonEmpty(series ...Series) []Series {
+	var res []Series
+	for _, s := range series {
+		if len(s.Points) > 0 {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+var pageTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"pct": func(a, b int32) string {
+		if b == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f%%", float64(a)/float64(b)*100)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; margin: 24px; color: #222; }
+h1 { font-size: 22px; } h2 { font-size: 17px; margin: 0 0 8px; }
+.meta { color: #666; font-size: 13px; }
+.charts { display: flex; flex-wrap: wrap; gap: 16px; }
+.card { border: 1px solid #ddd; border-radius: 6px; padding: 12px; width: 640px; }
+.chart { width: 100%; height: auto; }
+.chart .grid { stroke: #eee; } .chart .tick { font-size: 11px; fill: #666; } .chart .label { font-size: 12px; fill: #333; }
+.legend { font-size: 12px; } .legend-item { cursor: pointer; margin-right: 12px; user-select: none; }
+.legend-item i { display: inline-block; width: 10px; height: 10px; margin-right: 4px; border-radius: 2px; }
+.legend-item.off { opacity: 0.35; }
+table { border-collapse: collapse; font-size: 12px; margin-top: 8px; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: right; } th { background: #f6f6f6; }
+tr.partial td { color: #b07a00; }
+pre { background: #f6f6f6; padding: 12px; border-radius: 6px; font-size: 12px; overflow: auto; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">Source: {{.Run.Dir}} · Generated at {{.GeneratedAt}}
+{{- with .Run.Config}} · Model: {{.Model.Name}} · Backend: {{.Backend}} · Stream: {{.Stream}} · Input tokens: {{.InputTokens}} · Max tokens: {{.MaxTokens}}{{end}}</p>
+<div class="charts">
+{{range .Charts}}<div class="card"><h2>{{.Title}}</h2>{{.SVG}}</div>
+{{end}}</div>
+<h2 style="margin-top:24px">Rounds</h2>
+<table>
+<tr><th>concurrency</th><th>total</th><th>success</th><th>fail</th><th>success rate</th><th>req/s</th><th>output tokens/s</th><th>avg ms</th><th>P90 ms</th><th>P99 ms</th><th>first token ms</th><th>client tokens/s</th><th>start</th><th>end</th></tr>
+{{range .Run.Rounds}}<tr{{if .Partial}} class="partial" title="partial round"{{end}}><td>{{.Concurrency}}</td><td>{{.Total}}</td><td>{{.Success}}</td><td>{{.Fail}}</td><td>{{pct .Success .Total}}</td><td>{{printf "%.2f" .RequestPerSecond}}</td><td>{{printf "%.1f" .ServerOutputTokensPerSecond}}</td><td>{{printf "%.0f" .AvgTimeClientSide}}</td><td>{{printf "%.0f" .P90}}</td><td>{{printf "%.0f" .P99}}</td><td>{{printf "%.1f" .FirstTokenTime}}</td><td>{{printf "%.1f" .ClientOutputTokensPerSecond}}</td><td>{{.StartTime}}</td><td>{{.EndTime}}</td></tr>
+{{end}}</table>
+{{if .Config}}<h2 style="margin-top:24px">Config</h2>
+<pre>{{.Config}}</pre>{{end}}
+<script>
+document.querySelectorAll(".card").forEach(function (card) {
+  card.querySelectorAll(".legend-item").forEach(function (item) {
+    item.addEventListener("click", function () {
+      var off = item.classList.toggle("off");
+      var g = card.querySelector('.series[data-series="' + item.dataset.series + '"]');
+      if (g) { g.style.display = off ? "none" : ""; }
+    });
+  });
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,121 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/infer/param"
+	"github.com/nullxjx/llm_profiler/internal/perf/throughput"
+)
+
+var (
+	statisticsPattern = regexp.MustCompile(`^statistics_\d{4}-\d{2}-\d{2}-\d{2}-\d{2}-\d{2}\.json$`)
+	configPattern     = regexp.MustCompile(`^config_\d{4}-\d{2}-\d{2}-\d{2}-\d{2}-\d{2}\.json$`)
+	resultsPattern    = regexp.MustCompile(`^results_\d{4}-\d{2}-\d{2}-\d{2}-\d{2}-\d{2}_concurrency_(\d+)\.jsonl?$`)
+)
+
+// Run 一次压测保存在 saveDir 中的结果
+type Run struct {
+	Dir      string                          // 结果所在目录
+	Config   *config.Config                  // 压测配置，目录中没有配置文件时为 nil
+	Rounds   []*throughput.StatisticsSummary // 每一轮的统计结果，按并发度排序
+	Requests map[int][]param.Result          // 每一轮的单条请求结果，key 为并发度
+}
+
+// Load 读取 saveDir 中最新的统计结果和配置，withRequests 为 true 时同时读取单条请求结果
+func Load(dir string, withRequests bool) (*Run, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	// 文件名中的时间戳格式保证了按名字排序即按时间排序
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+
+	run := &Run{Dir: dir, Requests: make(map[int][]param.Result)}
+	var statisticsFile, configFile string
+	resultFiles := make(map[int]string)
+	for _, file := range files {
+		name := file.Name()
+		switch {
+		case statisticsPattern.MatchString(name):
+			statisticsFile = name
+		case configPattern.MatchString(name):
+			configFile = name
+		case resultsPattern.MatchString(name):
+			// 断点恢复时同一并发度可能被测试多次，取最新的一次
+			concurrency, _ := strconv.Atoi(resultsPattern.FindStringSubmatch(name)[1])
+			resultFiles[concurrency] = name
+		}
+	}
+	if statisticsFile == "" {
+		return nil, fmt.Errorf("no statistics file found in %s", dir)
+	}
+	if err = readJson(filepath.Join(dir, statisticsFile), &run.Rounds); err != nil {
+		return nil, err
+	}
+	sort.Slice(run.Rounds, func(i, j int) bool {
+		return run.Rounds[i].Concurrency < run.Rounds[j].Concurrency
+	})
+	if configFile != "" {
+		run.Config = &config.Config{}
+		if err = readJson(filepath.Join(dir, configFile), run.Config); err != nil {
+			return nil, err
+		}
+	}
+	if !withRequests {
+		return run, nil
+	}
+	for concurrency, name := range resultFiles {
+		results, err := readResults(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		run.Requests[concurrency] = results
+	}
+	return run, nil
+}
+
+func readJson(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse %s error: %v", path, err)
+	}
+	return nil
+}
+
+// readResults 读取单条请求结果，兼容 jsonl 格式和旧版本的 json 数组格式
+func readResults(path string) ([]param.Result, error) {
+	if !strings.HasSuffix(path, ".jsonl") {
+		var results []param.Result
+		return results, readJson(path, &results)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var results []param.Result
+	decoder := json.NewDecoder(file)
+	for {
+		var result param.Result
+		// 读到文件末尾，或者进程崩溃导致最后一行不完整时，保留已经读取的结果
+		if err = decoder.Decode(&result); err != nil {
+			break
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/perf/throughput"
+	"github.com/nullxjx/llm_profiler/internal/utils"
+	"github.com/nullxjx/llm_profiler/internal/validate"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Status 任务状态
+type Status string
+
+// Status 的枚举值
+const (
+	Queued    Status = "queued"
+	Running   Status = "running"
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+	Canceled  Status = "canceled"
+)
+
+var (
+	ErrQueueFull   = errors.New("job queue is full")
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job has already finished")
+)
+
+// RunFunc 执行一次压测
+type RunFunc func(ctx context.Context, cfg *config.Config) error
+
+// Progress 任务进度，从压测保存的断点信息中读取
+type Progress struct {
+	FinishedRounds  int `json:"finished_rounds"`
+	NextConcurrency int `json:"next_concurrency"`
+	EndConcurrency  int `json:"end_concurrency"`
+}
+
+// Job 一个压测任务
+type Job struct {
+	ID         string         `json:"id"`
+	Status     Status         `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	Config     *config.Config `json:"config"`
+	Progress   *Progress      `json:"progress,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	StartedAt  *time.Time     `json:"started_at,omitempty"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+// Manager 管理压测任务，任务按提交顺序依次执行，同一时间只有一个任务在压测
+type Manager struct {
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	order    []string // 任务提交顺序
+	queue    chan *Job
+	run      RunFunc
+	dataDir  string
+	inputDir string // 任务可以读取的数据集等输入文件所在的目录，为空时不限制
+	done     chan struct{}
+}
+
+// NewManager 创建任务管理器，最多排队 queueSize 个任务，任务结果保存在 dataDir/<job id> 目录下，
+// 任务的输入文件需要在 inputDir 目录下
+func NewManager(run RunFunc, dataDir, inputDir string, queueSize int) *Manager {
+	return &Manager{
+		jobs:     make(map[string]*Job),
+		queue:    make(chan *Job, queueSize),
+		run:      run,
+		dataDir:  dataDir,
+		inputDir: inputDir,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start 开始依次执行队列中的任务，ctx 被取消时取消正在执行的任务并退出
+func (m *Manager) Start(ctx context.Context) {
+	go func() {
+		defer close(m.done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case job := <-m.queue:
+				m.execute(ctx, job)
+			}
+		}
+	}()
+}
+
+// Done 返回一个在 Start 退出后被关闭的 channel，用于等待正在执行的任务保存结果
+func (m *Manager) Done() <-chan struct{} {
+	return m.done
+}
+
+// Submit 提交一个任务，队列已满时返回 ErrQueueFull
+func (m *Manager) Submit(cfg *config.Config) (*Job, error) {
+	id := fmt.Sprintf("%s-%s", time.Now().Format(utils.TimeFormat), utils.GenerateRandomStr(6))
+	cfg.SaveDir = filepath.Join(m.dataDir, id)
+	cfg.Tui = false // 服务模式下没有终端
+	if m.inputDir != "" {
+		if err := checkInputPaths(cfg, m.inputDir); err != nil {
+			return nil, err
+		}
+	}
+	if err := validate.Config(cfg); err != nil {
+		return nil, err
+	}
+	job := &Job{
+		ID:        id,
+		Status:    Queued,
+		Config:    cfg,
+		CreatedAt: time.Now(),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case m.queue <- job:
+	default:
+		return nil, ErrQueueFull
+	}
+	m.jobs[id] = job
+	m.order = append(m.order, id)
+	log.Infof("Job %s queued, model: %v, backend: %v", id, cfg.Model.Name, cfg.Backend)
+	return job.snapshot(), nil
+}
+
+// Get 查询任务
+func (m *Manager) Get(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return job.snapshot(), nil
+}
+
+// List 按提交顺序列出所有任务
+func (m *Manager) List() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]*Job, 0, len(m.order))
+	for _, id := range m.order {
+		jobs = append(jobs, m.jobs[id].snapshot())
+	}
+	return jobs
+}
+
+// Cancel 取消任务，排队中的任务不会再执行，正在执行的任务会保存已完成的结果后结束
+func (m *Manager) Cancel(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	switch job.Status {
+	case Queued:
+		job.finish(Canceled, nil)
+	case Running:
+		job.cancel()
+	default:
+		return nil, ErrJobFinished
+	}
+	log.Infof("Job %s canceled", id)
+	return job.snapshot(), nil
+}
+
+func (m *Manager) execute(parent context.Context, job *Job) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	m.mu.Lock()
+	if job.Status != Queued { // 排队时已被取消
+		m.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	job.Status = Running
+	job.StartedAt = &now
+	job.cancel = cancel
+	m.mu.Unlock()
+
+	log.Infof("Job %s started", job.ID)
+	// 接口返回的任务状态中包含 job.Config，运行时使用单独的拷贝，避免与查询接口的读取竞争
+	cfg, err := config.Clone(job.Config)
+	if err == nil {
+		err = m.run(ctx, cfg)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case ctx.Err() != nil:
+		job.finish(Canceled, err)
+	case err != nil:
+		job.finish(Failed, err)
+	default:
+		job.finish(Succeeded, nil)
+	}
+	log.Infof("Job %s %s", job.ID, job.Status)
+}
+
+func (j *Job) finish(status Status, err error) {
+	now := time.Now()
+	j.Status = status
+	j.FinishedAt = &now
+	if err != nil {
+		j.Error = err.Error()
+	}
+}
+
+// snapshot 返回任务当前状态的拷贝，调用时需持有锁
+func (j *Job) snapshot() *Job {
+	s := *j
+	s.cancel = nil
+	if cp, err := throughput.ReadCheckpoint(j.Config.SaveDir); err == nil {
+		s.Progress = &Progress{
+			FinishedRounds:  len(cp.Rounds),
+			NextConcurrency: cp.NextConcurrency,
+			EndConcurrency:  j.Config.EndConcurrency,
+		}
+	}
+	return &s
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/validate"
+)
+
+// inputPath 配置中会被读取的一个输入文件
+type inputPath struct {
+	key  string
+	path string
+}
+
+// inputPaths 返回配置中会被读取的输入文件和目录，这些文件的内容可能出现在结果中
+func inputPaths(cfg *config.Config) []inputPath {
+	var paths []inputPath
+	add := func(key, path string) {
+		if path != "" {
+			paths = append(paths, inputPath{key, path})
+		}
+	}
+	if cfg.Prompt.GetSource() == config.PromptSourceBuckets {
+		add("prompt.path", cfg.Prompt.BucketDir())
+	} else {
+		add("prompt.path", cfg.Prompt.Path)
+	}
+	add("prompt.tokenizer", cfg.Prompt.Tokenizer)
+	add("session.path", cfg.Session.Path)
+	add("output.length.file", cfg.Output.Length.File)
+	for i, c := range cfg.Workload.Classes {
+		add(fmt.Sprintf("workload.classes[%d].input.file", i), c.Input.File)
+		add(fmt.Sprintf("workload.classes[%d].output.file", i), c.Output.File)
+	}
+	return paths
+}
+
+// checkInputPaths 校验所有输入文件都在 dir 目录下，避免通过接口提交的任务读取服务器上的任意文件
+func checkInputPaths(cfg *config.Config, dir string) error {
+	root, err := resolve(dir)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	for _, p := range inputPaths(cfg) {
+		path, err := resolve(p.path)
+		if err != nil {
+			return err
+		}
+		if rel, err := filepath.Rel(root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			problems = append(problems, fmt.Sprintf("%s %q is outside the input dir %s", p.key, p.path, dir))
+		}
+	}
+	if len(problems) > 0 {
+		return &validate.Error{Problems: problems}
+	}
+	return nil
+}
+
+// resolve 返回路径的绝对路径，路径存在时解析其中的符号链接
+func resolve(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real, nil
+	}
+	return abs, nil
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/validate"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxConfigSize 提交任务时配置内容的最大字节数
+const maxConfigSize = 1 << 20
+
+// NewHandler 创建任务管理的 REST 接口
+//
+//	POST /jobs                 提交任务，请求体为 yaml 或 json 格式的配置
+//	GET  /jobs                 列出所有任务
+//	GET  /jobs/{id}            查询任务状态和进度
+//	POST /jobs/{id}/cancel     取消任务
+//	GET  /jobs/{id}/results    下载任务结果目录的 tar.gz 压缩包
+//	GET  /metrics              prometheus 指标
+func NewHandler(m *Manager) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(io.LimitReader(r.Body, maxConfigSize))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		cfg, err := config.ParseConf(data)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("parse config error: %v", err))
+			return
+		}
+		job, err := m.Submit(cfg)
+		if err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		writeJson(w, http.StatusAccepted, job)
+	})
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, m.List())
+	})
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		job, err := m.Get(r.PathValue("id"))
+		if err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		writeJson(w, http.StatusOK, job)
+	})
+	mux.HandleFunc("POST /jobs/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		job, err := m.Cancel(r.PathValue("id"))
+		if err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		writeJson(w, http.StatusOK, job)
+	})
+	mux.HandleFunc("GET /jobs/{id}/results", func(w http.ResponseWriter, r *http.Request) {
+		job, err := m.Get(r.PathValue("id"))
+		if err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		if _, err = os.Stat(job.Config.SaveDir); err != nil {
+			writeError(w, http.StatusNotFound, errors.New("job has no results yet"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.tar.gz"`, job.ID))
+		if err = writeTarGz(w, job.Config.SaveDir, job.ID); err != nil {
+			log.Errorf("write results of job %s error: %v", job.ID, err)
+		}
+	})
+	mux.Handle("GET /metrics", promhttp.Handler())
+	return mux
+}
+
+func statusOf(err error) int {
+	var invalid *validate.Error
+	switch {
+	case errors.As(err, &invalid):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrQueueFull):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrJobFinished):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJson(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("write response error: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJson(w, code, map[string]string{"error": err.Error()})
+}
+
+// writeTarGz 把 dir 目录打包为 tar.gz 写入 w，包内文件都放在 prefix 目录下
+func writeTarGz(w io.Writer, dir, prefix string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(prefix, rel))
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.CopyN(tw, file, header.Size) // 文件可能正在被写入，只拷贝打包时的大小
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
@@ -0,0 +1,400 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// spaceMarker sentencepiece 风格的分词器中用来表示空格的字符
+const spaceMarker = "▁"
+
+// maxCacheSize 单词分词结果缓存的最大数量
+const maxCacheSize = 1 << 20
+
+// maxWordLen 单词的最大字符数，更长的单词（例如没有空格的中文）切分后分别合并，避免 BPE 合并耗时过长
+const maxWordLen = 256
+
+// gpt2Pattern byte-level 分词器默认的预分词正则，去掉了 go 不支持的 (?!\S)
+const gpt2Pattern = `'s|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+`
+
+// Tokenizer 从 huggingface 的 tokenizer.json 文件加载的 BPE 分词器，支持 byte-level（gpt2、llama3、qwen 等）
+// 和 sentencepiece（llama2、mistral 等）两种风格，用于统计 token 数和构造给定 token 数的 prompt。
+//
+// 与 huggingface tokenizers 的已知差异（见 tokenizer_test.go）：
+//   - byte-level：go 的正则不支持 (?!\S)，连续空白被切为一段，而 huggingface 把其中最后一个空格留给后面的单词，
+//     包含连续空格或换行的文本 token 数会相差几个，普通的单空格文本没有差异
+//   - sentencepiece：在每段连续 ▁ 之前切分单词，当词表中没有把非 ▁ 字符与其后的 ▁ 合并的 merge 时（llama2、mistral 均如此），
+//     与不切分的 huggingface 结果相同；pre_tokenizer 为 Metaspace 且 split 为 true 时 huggingface 在每个 ▁ 之前切分，连续空格处可能不同
+//   - 文本中的 added tokens（例如 <|endoftext|>）按普通文本编码，不识别为特殊 token
+type Tokenizer struct {
+	vocab        map[string]int
+	tokens       map[int]string // id 到 token 的映射，包含 added tokens
+	special      map[int]bool
+	ranks        map[pair]int
+	byteLevel    bool
+	byteFallback bool
+	unkID        int
+	pattern      *regexp.Regexp
+	byteEncoder  [256]string
+	byteDecoder  map[rune]byte
+
+	mu    sync.RWMutex
+	cache map[string][]int
+}
+
+type pair struct {
+	a, b string
+}
+
+type tokenizerFile struct {
+	AddedTokens []struct {
+		ID      int    `json:"id"`
+		Content string `json:"content"`
+		Special bool   `json:"special"`
+	} `json:"added_tokens"`
+	PreTokenizer json.RawMessage `json:"pre_tokenizer"`
+	Model        struct {
+		Type         string            `json:"type"`
+		Vocab        map[string]int    `json:"vocab"`
+		Merges       []json.RawMessage `json:"merges"`
+		UnkToken     *string           `json:"unk_token"`
+		ByteFallback bool              `json:"byte_fallback"`
+	} `json:"model"`
+}
+
+// loaded 一次加载的结果
+type loaded struct {
+	tok *Tokenizer
+	err error
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]loaded) // 已加载的分词器，按路径缓存
+)
+
+// LoadCached 加载分词器，同一路径只加载一次，加载失败的错误同样被缓存
+func LoadCached(path string) (*Tokenizer, error) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	l, ok := cache[path]
+	if !ok {
+		l.tok, l.err = Load(path)
+		cache[path] = l
+	}
+	return l.tok, l.err
+}
+
+// Load 加载 huggingface 格式的 tokenizer.json 文件
+func Load(path string) (*Tokenizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f tokenizerFile
+	if err = json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse %s error: %v", path, err)
+	}
+	if f.Model.Type != "" && f.Model.Type != "BPE" {
+		return nil, fmt.Errorf("unsupported tokenizer model %s, only BPE is supported", f.Model.Type)
+	}
+	if len(f.Model.Vocab) == 0 {
+		return nil, fmt.Errorf("empty vocab in %s", path)
+	}
+
+	t := &Tokenizer{
+		vocab:        f.Model.Vocab,
+		tokens:       make(map[int]string, len(f.Model.Vocab)+len(f.AddedTokens)),
+		special:      make(map[int]bool),
+		ranks:        make(map[pair]int, len(f.Model.Merges)),
+		byteFallback: f.Model.ByteFallback,
+		unkID:        -1,
+		cache:        make(map[string][]int),
+	}
+	for token, id := range f.Model.Vocab {
+		t.tokens[id] = token
+	}
+	for _, added := range f.AddedTokens {
+		t.tokens[added.ID] = added.Content
+		if added.Special {
+			t.special[added.ID] = true
+		}
+	}
+	if f.Model.UnkToken != nil {
+		if id, ok := t.vocab[*f.Model.UnkToken]; ok {
+			t.unkID = id
+		}
+	}
+	for i, raw := range f.Model.Merges {
+		a, b, err := parseMerge(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parse merge %d error: %v", i, err)
+		}
+		t.ranks[pair{a, b}] = i
+	}
+
+	var pre any
+	_ = json.Unmarshal(f.PreTokenizer, &pre)
+	t.byteLevel = hasType(pre, "ByteLevel")
+	if t.byteLevel {
+		t.initByteLevel(splitPattern(pre))
+	}
+	return t, nil
+}
+
+// parseMerge 兼容 "a b" 和 ["a", "b"] 两种 merges 格式
+func parseMerge(raw json.RawMessage) (string, string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		a, b, ok := strings.Cut(s, " ")
+		if !ok {
+			return "", "", fmt.Errorf("invalid merge %q", s)
+		}
+		return a, b, nil
+	}
+	var parts []string
+	if err := json.Unmarshal(raw, &parts); err != nil || len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid merge %s", raw)
+	}
+	return parts[0], parts[1], nil
+}
+
+// hasType 判断 pre_tokenizer 配置中是否包含给定类型
+func hasType(v any, typ string) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		if v["type"] == typ {
+			return true
+		}
+		for _, child := range v {
+			if hasType(child, typ) {
+				return true
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if hasType(child, typ) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitPattern 返回 pre_tokenizer 中 Split 的正则，没有时返回空字符串
+func splitPattern(v any) string {
+	switch v := v.(type) {
+	case map[string]any:
+		if v["type"] == "Split" {
+			if p, ok := v["pattern"].(map[string]any); ok {
+				if re, ok := p["Regex"].(string); ok {
+					return re
+				}
+			}
+		}
+		for _, child := range v {
+			if re := splitPattern(child); re != "" {
+				return re
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if re := splitPattern(child); re != "" {
+				return re
+			}
+		}
+	}
+	return ""
+}
+
+func (t *Tokenizer) initByteLevel(pattern string) {
+	// go 的正则不支持零宽断言，去掉后连续空白不再把最后一个空格留给后面的单词，见 Tokenizer 的说明
+	pattern = strings.ReplaceAll(pattern, `(?!\S)`, "")
+	re, err := regexp.Compile(pattern)
+	if pattern == "" || err != nil {
+		re = regexp.MustCompile(gpt2Pattern)
+	}
+	t.pattern = re
+
+	// 与 gpt2 的 bytes_to_unicode 一致，把每个字节映射为一个可见字符
+	t.byteDecoder = make(map[rune]byte, 256)
+	n := 0
+	for b := 0; b < 256; b++ {
+		r := rune(b)
+		if !(b >= '!' && b <= '~' || b >= 0xA1 && b <= 0xAC || b >= 0xAE && b <= 0xFF) {
+			r = rune(256 + n)
+			n++
+		}
+		t.byteEncoder[b] = string(r)
+		t.byteDecoder[r] = byte(b)
+	}
+}
+
+// VocabSize 返回词表大小，包含 added tokens
+func (t *Tokenizer) VocabSize() int {
+	return len(t.tokens)
+}
+
+// IsSpecial 判断是否为特殊 token，例如 <s>、<|endoftext|>
+func (t *Tokenizer) IsSpecial(id int) bool {
+	return t.special[id]
+}
+
+// Token 返回 id 对应的 token 文本，不存在时返回 false
+func (t *Tokenizer) Token(id int) (string, bool) {
+	token, ok := t.tokens[id]
+	return token, ok
+}
+
+// Count 统计文本的 token 数，不包含 bos 等特殊 token
+func (t *Tokenizer) Count(text string) int {
+	return len(t.Encode(text))
+}
+
+// Encode 把文本编码为 token id，不添加 bos 等特殊 token
+func (t *Tokenizer) Encode(text string) []int {
+	var ids []int
+	for _, word := range t.words(text) {
+		for utf8.RuneCountInString(word) > maxWordLen {
+			cut := 0
+			for i := 0; i < maxWordLen; i++ {
+				_, size := utf8.DecodeRuneInString(word[cut:])
+				cut += size
+			}
+			ids = append(ids, t.encodeWord(word[:cut])...)
+			word = word[cut:]
+		}
+		ids = append(ids, t.encodeWord(word)...)
+	}
+	return ids
+}
+
+// words 预分词，byte-level 风格按正则切分后把每个字节映射为可见字符，sentencepiece 风格把空格替换为 ▁ 后在每段连续 ▁ 之前切分
+func (t *Tokenizer) words(text string) []string {
+	if t.byteLevel {
+		pieces := t.pattern.FindAllString(text, -1)
+		for i, piece := range pieces {
+			var sb strings.Builder
+			for j := 0; j < len(piece); j++ {
+				sb.WriteString(t.byteEncoder[piece[j]])
+			}
+			pieces[i] = sb.String()
+		}
+		return pieces
+	}
+	if text == "" {
+		return nil
+	}
+	text = spaceMarker + strings.ReplaceAll(text, " ", spaceMarker)
+	var words []string
+	start, prev := 0, rune(0)
+	for i, r := range text {
+		if i > 0 && string(r) == spaceMarker && string(prev) != spaceMarker {
+			words = append(words, text[start:i])
+			start = i
+		}
+		prev = r
+	}
+	return append(words, text[start:])
+}
+
+func (t *Tokenizer) encodeWord(word string) []int {
+	t.mu.RLock()
+	ids, ok := t.cache[word]
+	t.mu.RUnlock()
+	if ok {
+		return ids
+	}
+
+	symbols := make([]string, 0, utf8.RuneCountInString(word))
+	for _, r := range word {
+		symbols = append(symbols, string(r))
+	}
+	for len(symbols) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i < len(symbols)-1; i++ {
+			if rank, ok := t.ranks[pair{symbols[i], symbols[i+1]}]; ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		a, b := symbols[best], symbols[best+1]
+		merged := symbols[:0:0]
+		for i := 0; i < len(symbols); i++ {
+			if i < len(symbols)-1 && symbols[i] == a && symbols[i+1] == b {
+				merged = append(merged, a+b)
+				i++
+				continue
+			}
+			merged = append(merged, symbols[i])
+		}
+		symbols = merged
+	}
+
+	ids = make([]int, 0, len(symbols))
+	for _, s := range symbols {
+		if id, ok := t.vocab[s]; ok {
+			ids = append(ids, id)
+			continue
+		}
+		if t.byteFallback {
+			for j := 0; j < len(s); j++ {
+				if id, ok := t.vocab[fmt.Sprintf("<0x%02X>", s[j])]; ok {
+					ids = append(ids, id)
+				}
+			}
+			continue
+		}
+		if t.unkID >= 0 {
+			ids = append(ids, t.unkID)
+		}
+	}
+
+	t.mu.Lock()
+	if len(t.cache) < maxCacheSize {
+		t.cache[word] = ids
+	}
+	t.mu.Unlock()
+	return ids
+}
+
+// Decode 把 token id 解码为文本，跳过特殊 token
+func (t *Tokenizer) Decode(ids []int) string {
+	var buf []byte
+	for _, id := range ids {
+		token, ok := t.tokens[id]
+		if !ok || t.special[id] {
+			continue
+		}
+		if t.byteLevel {
+			for _, r := range token {
+				if b, ok := t.byteDecoder[r]; ok {
+					buf = append(buf, b)
+				} else {
+					buf = utf8.AppendRune(buf, r)
+				}
+			}
+			continue
+		}
+		if len(token) == 6 && strings.HasPrefix(token, "<0x") && strings.HasSuffix(token, ">") {
+			if b, err := strconv.ParseUint(token[3:5], 16, 8); err == nil {
+				buf = append(buf, byte(b))
+				continue
+			}
+		}
+		buf = append(buf, strings.ReplaceAll(token, spaceMarker, " ")...)
+	}
+	text := string(buf)
+	if !t.byteLevel {
+		text = strings.TrimPrefix(text, " ")
+	}
+	return text
+}
@@ -0,0 +1,85 @@
+package tokenizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+// goldenCase 一条文本在 huggingface tokenizers 中的分词结果。hf 为 huggingface 的结果，
+// ours 不为空时表示本实现与 huggingface 存在已知差异，记录本实现的结果
+//
+// testdata 中是两个按 huggingface tokenizer.json 格式构造的小词表，hf 按 huggingface 的预分词正则、
+// normalizer 和 BPE 合并规则逐步推导得到，可以用下面的命令核对：
+//
+//	python -c 'from tokenizers import Tokenizer; print(Tokenizer.from_file("testdata/bytelevel.json").encode("hello  world").tokens)'
+type goldenCase struct {
+	text string
+	hf   []string
+	ours []string
+}
+
+// byteLevelCases gpt2 风格（ByteLevel 预分词，默认正则）的用例，Ġ 表示空格，Ċ 表示换行
+var byteLevelCases = []goldenCase{
+	{text: "", hf: nil},
+	{text: "hello world", hf: []string{"hello", "Ġworld"}},
+	{text: "he's", hf: []string{"he", "'", "s"}},
+	{text: "héllo", hf: []string{"h", "Ã", "©", "ll", "o"}},
+	{text: "hello  ", hf: []string{"hello", "ĠĠ"}},
+	// 以下为 (?!\S) 带来的差异：huggingface 中连续空白的最后一个空格留给后面的单词，本实现把连续空白切为一段
+	{text: "hello  world", hf: []string{"hello", "Ġ", "Ġworld"}, ours: []string{"hello", "ĠĠ", "world"}},
+	{text: "hello   world", hf: []string{"hello", "ĠĠ", "Ġworld"}, ours: []string{"hello", "ĠĠ", "Ġ", "world"}},
+	{text: "hello\n\nworld", hf: []string{"hello", "Ċ", "Ċ", "world"}, ours: []string{"hello", "ĊĊ", "world"}},
+	{text: "hello\n world", hf: []string{"hello", "Ċ", "Ġworld"}, ours: []string{"hello", "Ċ", "Ġ", "world"}},
+}
+
+// sentencePieceCases llama2 风格（Prepend 和 Replace normalizer，没有 pre_tokenizer，byte_fallback）的用例
+var sentencePieceCases = []goldenCase{
+	{text: "", hf: nil},
+	{text: "hello world", hf: []string{"▁hello", "▁world"}},
+	{text: " hello", hf: []string{"▁", "▁hello"}},
+	{text: "héllo", hf: []string{"▁", "h", "<0xC3>", "<0xA9>", "ll", "o"}},
+	{text: "hello  ", hf: []string{"▁hello", "▁▁"}},
+	{text: "hello  world", hf: []string{"▁hello", "▁", "▁world"}},
+	{text: "hello   world", hf: []string{"▁hello", "▁▁", "▁world"}},
+	{text: "hello\n\nworld", hf: []string{"▁hello", "<0x0A>", "<0x0A>", "world"}},
+	{text: "hello\n world", hf: []string{"▁hello", "<0x0A>", "▁world"}},
+}
+
+func TestByteLevelGolden(t *testing.T) {
+	testGolden(t, "testdata/bytelevel.json", byteLevelCases)
+}
+
+func TestSentencePieceGolden(t *testing.T) {
+	testGolden(t, "testdata/sentencepiece.json", sentencePieceCases)
+}
+
+func testGolden(t *testing.T, path string, cases []goldenCase) {
+	tok, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		want := c.hf
+		if c.ours != nil {
+			want = c.ours
+		}
+		ids := tok.Encode(c.text)
+		var got []string
+		for _, id := range ids {
+			token, _ := tok.Token(id)
+			got = append(got, token)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Encode(%q) = %q, want %q", c.text, got, want)
+		}
+		if n := tok.Count(c.text); n != len(want) {
+			t.Errorf("Count(%q) = %d, want %d", c.text, n, len(want))
+		}
+		if c.ours != nil {
+			t.Logf("Count(%q) = %d, huggingface = %d", c.text, len(c.ours), len(c.hf))
+		}
+		if text := tok.Decode(ids); text != c.text {
+			t.Errorf("Decode(Encode(%q)) = %q", c.text, text)
+		}
+	}
+}
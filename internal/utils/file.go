@@ -36,38 +36,23 @@ func IsDirEmpty(dir string) bool {
 	return false
 }
 
-// Save2Json 保存数据到json文件中，saveDir为保存文件的路径
+// Save2Json 保存数据到json文件中，saveDir为保存文件的路径，失败时只打印日志
 func Save2Json(v any, saveDir string) {
-	jsonData, err := json.Marshal(v)
-	if err != nil {
-		log.Errorf("Error encoding JSON: %v", err)
-		return
-	}
-
-	// 获取文件所在的目录
-	dir := filepath.Dir(saveDir)
-
-	// 创建目录（如果不存在）
-	err = os.MkdirAll(dir, os.ModePerm)
-	if err != nil {
-		log.Errorf("failed to create directories: %v", err)
-		return
+	if err := WriteJson(v, saveDir); err != nil {
+		log.Errorf("Error saving JSON to %v: %v", saveDir, err)
 	}
+}
 
-	file, err := os.Create(saveDir)
+// WriteJson 保存数据到json文件中，文件所在的目录不存在时自动创建，返回编码或写入的错误
+func WriteJson(v any, path string) error {
+	jsonData, err := json.Marshal(v)
 	if err != nil {
-		log.Errorf("Error creating file: %v", err)
-		return
+		return fmt.Errorf("encode json error: %v", err)
 	}
-	defer file.Close()
-
-	_, err = file.Write(jsonData)
-	if err != nil {
-		log.Errorf("Error writing JSON data to file: %v", err)
-		return
+	if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
 	}
-
-	//log.Infof("JSON data saved to file: %v", saveDir)
+	return os.WriteFile(path, jsonData, 0644)
 }
 
 func KeepFinalResult(saveDir string) {
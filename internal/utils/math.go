@@ -1,9 +1,16 @@
 package utils
 
-import "math"
+import (
+	"math"
+	"math/rand"
+
+	"github.com/montanaflynn/stats"
+)
 
 const (
 	MinimumCount = 3
+	// bootstrapRounds 分位数 bootstrap 检验的重采样次数
+	bootstrapRounds = 2000
 )
 
 // IsClose 判断两个浮点数是否接近
@@ -43,3 +50,153 @@ func MeanWithoutMinMax(numbers []float64) float64 {
 	mean := sum / float64(len(numbers)-2)
 	return mean
 }
+
+// WelchTTest 对两组样本的均值做 Welch t 检验，返回双侧 p 值，样本不足时返回 1
+func WelchTTest(a, b []float64) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		return 1
+	}
+	meanA, varA := meanVariance(a)
+	meanB, varB := meanVariance(b)
+	na, nb := float64(len(a)), float64(len(b))
+	se := varA/na + varB/nb
+	if se == 0 {
+		if meanA == meanB {
+			return 1
+		}
+		return 0
+	}
+	t := (meanA - meanB) / math.Sqrt(se)
+	df := se * se / (varA*varA/(na*na*(na-1)) + varB*varB/(nb*nb*(nb-1)))
+	// 自由度为 df 的 t 分布双侧 p 值
+	return regularizedIncompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// PairedTTest 对配对样本的差值做 t 检验，检验差值的均值是否为 0，返回双侧 p 值，样本不足时返回 1
+func PairedTTest(diffs []float64) float64 {
+	if len(diffs) < 2 {
+		return 1
+	}
+	mean, variance := meanVariance(diffs)
+	n := float64(len(diffs))
+	if variance == 0 {
+		if mean == 0 {
+			return 1
+		}
+		return 0
+	}
+	t := mean / math.Sqrt(variance/n)
+	df := n - 1
+	return regularizedIncompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// BootstrapPercentileTest 用 bootstrap 检验两组样本的第 percent 分位数是否相同，返回双侧 p 值，样本不足时返回 1。
+// 对两组样本分别有放回重采样，由分位数差值的 bootstrap 分布中落在 0 两侧的比例得到 p 值，
+// 与置信区间是否包含 0 等价，只影响尾部的变化也能被检出。使用固定的随机种子，相同输入的结果相同
+func BootstrapPercentileTest(a, b []float64, percent float64) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		return 1
+	}
+	r := rand.New(rand.NewSource(1))
+	resampleA, resampleB := make([]float64, len(a)), make([]float64, len(b))
+	var below, above float64
+	for i := 0; i < bootstrapRounds; i++ {
+		for j := range resampleA {
+			resampleA[j] = a[r.Intn(len(a))]
+		}
+		for j := range resampleB {
+			resampleB[j] = b[r.Intn(len(b))]
+		}
+		pa, _ := stats.Percentile(resampleA, percent)
+		pb, _ := stats.Percentile(resampleB, percent)
+		switch diff := pb - pa; {
+		case diff < 0:
+			below++
+		case diff > 0:
+			above++
+		default: // 延迟按毫秒取整，差值为 0 的情况两侧各算一半
+			below += 0.5
+			above += 0.5
+		}
+	}
+	return math.Min(1, 2*math.Min(below, above)/bootstrapRounds)
+}
+
+// meanVariance 计算均值和样本方差
+func meanVariance(numbers []float64) (float64, float64) {
+	var sum float64
+	for _, num := range numbers {
+		sum += num
+	}
+	mean := sum / float64(len(numbers))
+	var squares float64
+	for _, num := range numbers {
+		squares += (num - mean) * (num - mean)
+	}
+	return mean, squares / float64(len(numbers)-1)
+}
+
+// regularizedIncompleteBeta 正则化不完全 beta 函数 I_x(a, b)，使用连分式展开计算
+func regularizedIncompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lgab, _ := math.Lgamma(a + b)
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+	// 连分式在 x < (a+1)/(a+b+2) 时收敛更快，否则使用对称关系
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(a, b, x) / a
+	}
+	return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+}
+
+func betaContinuedFraction(a, b, x float64) float64 {
+	const (
+		maxIterations = 200
+		epsilon       = 1e-12
+		tiny          = 1e-300
+	)
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+		// 偶数项
+		numerator := fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+		d = 1 + numerator*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + numerator/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+		// 奇数项
+		numerator = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+		d = 1 + numerator*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + numerator/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
@@ -1,10 +1,7 @@
 package utils
 
 import (
-	"encoding/json"
-	"fmt"
 	"math/rand"
-	"os"
 	"time"
 )
 
@@ -23,57 +20,9 @@ func GenerateRandomStr(length int) string {
 }
 
 type Input struct {
-	Prompt string `json:"prompt"`
-	Tokens int    `json:"tokens"`
-}
-
-// ReadPrompts 从文件中读取给定长度的prompts
-// 输入的 promptLength 表示prompt中的token数量
-func ReadPrompts(promptLength int) ([]string, error) {
-	var result []string
-	inputDataPath := fmt.Sprintf("data/ShareGPT_V3_unfiltered_cleaned_split/input_tokens_%d.json", promptLength)
-	file, err := os.Open(inputDataPath)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	decoder := json.NewDecoder(file)
-	var inputs []Input
-	err = decoder.Decode(&inputs)
-	if err != nil {
-		return nil, err
-	}
-
-	promptPrefix := "Please provide a comprehensive and detailed response based on the following information: "
-	for _, input := range inputs {
-		result = append(result, promptPrefix+input.Prompt)
-	}
-	return result, nil
-}
-
-// ReadPromptsWithTokens 从文件中读取给定长度的prompts，包含其token信息统计
-// 输入的 promptLength 表示prompt中的token数量
-func ReadPromptsWithTokens(promptLength int) ([]Input, error) {
-	inputDataPath := fmt.Sprintf("data/ShareGPT_V3_unfiltered_cleaned_split/input_tokens_%d.json", promptLength)
-	file, err := os.Open(inputDataPath)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	decoder := json.NewDecoder(file)
-	var inputs []Input
-	err = decoder.Decode(&inputs)
-	if err != nil {
-		return nil, err
-	}
-
-	promptPrefix := "Please provide a comprehensive and detailed response based on the following information: "
-	for _, input := range inputs {
-		input.Prompt = promptPrefix + input.Prompt
-	}
-	return inputs, nil
+	Prompt       string `json:"prompt"`
+	Tokens       int    `json:"tokens"`
+	OutputTokens int    `json:"output_tokens,omitempty"` // 参考回答的token数，dataset build 生成的数据集才有
 }
 
 //// ReadPrompts 生成测试需要的prompts
@@ -0,0 +1,409 @@
+package validate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/infer"
+	"github.com/nullxjx/llm_profiler/internal/infer/type/backend"
+	"github.com/nullxjx/llm_profiler/internal/utils"
+	"github.com/nullxjx/llm_profiler/internal/workload"
+	"github.com/nullxjx/llm_profiler/pkg/store/cos"
+)
+
+// lfsPointerPrefix 没有执行 git lfs pull 时，数据集文件的内容是以此开头的指针文件
+const lfsPointerPrefix = "version https://git-lfs"
+
+// streamBackends 支持流式请求的推理后端
+var streamBackends = map[string]bool{
+	string(backend.VLLM): true,
+	string(backend.TRT):  true,
+}
+
+// Error 配置校验发现的所有问题
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid config, %d problems found:\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Config 校验配置，一次性返回所有问题，没有问题时返回 nil
+func Config(cfg *config.Config) error {
+	v := &validator{}
+	v.global(cfg)
+	if cfg.Matrix.Empty() {
+		v.run(cfg, "")
+	} else {
+		// 参数矩阵的每个组合都需要是合法的配置
+		for _, cell := range config.ExpandMatrix(cfg) {
+			v.run(cell.Config, cell.Name+": ")
+		}
+	}
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &Error{Problems: v.problems}
+}
+
+type validator struct {
+	problems []string
+	seen     map[string]bool
+}
+
+func (v *validator) add(format string, args ...any) {
+	p := fmt.Sprintf(format, args...)
+	if v.seen == nil {
+		v.seen = make(map[string]bool)
+	}
+	if !v.seen[p] {
+		v.seen[p] = true
+		v.problems = append(v.problems, p)
+	}
+}
+
+// global 校验与参数矩阵无关的配置
+func (v *validator) global(cfg *config.Config) {
+	if cfg.Model.Name == "" {
+		v.add("model.name is required")
+	}
+	v.modelMix(cfg)
+	if len(cfg.Endpoints) == 0 && cfg.Domain == "" && (cfg.ServerIp == "" || cfg.Port <= 0) {
+		v.add("either endpoints, domain or serverIp and port are required")
+	}
+	v.endpoints(cfg, "")
+	if cfg.SaveDir == "" {
+		v.add("saveDir is required")
+	}
+	if cfg.StartConcurrency <= 0 {
+		v.add("startConcurrency must be > 0, got %d", cfg.StartConcurrency)
+	}
+	if cfg.EndConcurrency < cfg.StartConcurrency {
+		v.add("endConcurrency (%d) must be >= startConcurrency (%d)", cfg.EndConcurrency, cfg.StartConcurrency)
+	}
+	if cfg.Increment <= 0 {
+		v.add("increment must be > 0, got %d", cfg.Increment)
+	}
+	if cfg.Duration <= 0 {
+		v.add("duration must be > 0 minutes, got %d", cfg.Duration)
+	}
+	if cfg.MaxStreamSpeed < 0 {
+		v.add("maxStreamSpeed must be >= 0, got %v", cfg.MaxStreamSpeed)
+	}
+	switch cfg.ResultText {
+	case config.ResultTextFull, config.ResultTextHash, config.ResultTextNone:
+	default:
+		v.add("resultText must be one of %s, %s, %s, got %q",
+			config.ResultTextFull, config.ResultTextHash, config.ResultTextNone, cfg.ResultText)
+	}
+
+	// SLO 阈值需要为正数且递增，超过请求超时时间的阈值没有意义
+	if !sort.SliceIsSorted(cfg.TimeThresholds, func(i, j int) bool {
+		return cfg.TimeThresholds[i] < cfg.TimeThresholds[j]
+	}) {
+		v.add("timeThresholds must be in ascending order, got %v", cfg.TimeThresholds)
+	}
+	for _, t := range cfg.TimeThresholds {
+		if t <= 0 {
+			v.add("timeThresholds must be > 0 ms, got %d", t)
+		}
+	}
+
+	v.workload(cfg)
+	v.session(cfg)
+	v.sharedPrefix(cfg)
+	v.output(cfg)
+	v.sampling(cfg)
+	v.ab(cfg)
+
+	if cfg.Save2Cos {
+		requireEnv(v, "save2Cos", cos.EnvSecretID, cos.EnvSecretKey, cos.EnvBucket, cos.EnvRegion)
+	}
+	if cfg.SendMsg {
+		if !cfg.Save2Cos {
+			v.add("sendMsg requires save2Cos, the message contains the cos download url")
+		}
+		requireEnv(v, "sendMsg", utils.EnvWebhookUrl)
+	}
+}
+
+// run 校验单次吞吐量测试相关的配置，prefix 用于标识参数矩阵中的组合
+func (v *validator) run(cfg *config.Config, prefix string) {
+	v.backend(cfg, prefix)
+	if cfg.AB.Enabled() {
+		v.backend(cfg.Candidate(), prefix+"ab.candidate.")
+	}
+	if !cfg.Stream && cfg.RequestTimeout <= 0 {
+		v.add("%srequestTimeout must be > 0 ms for non-stream requests, got %d", prefix, cfg.RequestTimeout)
+	}
+	if cfg.Stream && (cfg.StreamThresholds <= 0 || cfg.StreamThresholds > 100) {
+		v.add("%sstreamThresholds must be in (0, 100], got %d", prefix, cfg.StreamThresholds)
+	}
+	if !cfg.Stream && cfg.RequestTimeout > 0 {
+		for _, t := range cfg.TimeThresholds {
+			if t > int64(cfg.RequestTimeout) {
+				v.add("%stimeThresholds %d ms exceeds requestTimeout %d ms and can never be reached",
+					prefix, t, cfg.RequestTimeout)
+			}
+		}
+	}
+	if cfg.MaxTokens == 0 {
+		v.add("%smaxTokens must be > 0", prefix)
+	}
+	if cfg.Temperature < 0 {
+		v.add("%stemperature must be >= 0, got %v", prefix, cfg.Temperature)
+	}
+	if cfg.Stream && cfg.Sampling.BeamWidth > 1 {
+		v.add("%ssampling.beamWidth does not support stream", prefix)
+	}
+	if cfg.Sampling.MinTokens > int(cfg.MaxTokens) {
+		v.add("%ssampling.minTokens %d exceeds maxTokens %d", prefix, cfg.Sampling.MinTokens, cfg.MaxTokens)
+	}
+	v.prompt(cfg, prefix)
+}
+
+// backend 校验与推理后端相关的配置，A/B 对比测试的两个服务都需要支持
+func (v *validator) backend(cfg *config.Config, prefix string) {
+	name := strings.ToLower(cfg.Backend)
+	switch name {
+	case string(backend.VLLM), string(backend.TRT), string(backend.TGI):
+	default:
+		v.add("%sbackend must be one of vllm, trt, tgi, got %q", prefix, cfg.Backend)
+	}
+	if cfg.Stream && name != "" && !streamBackends[name] {
+		v.add("%sbackend %s does not support stream", prefix, cfg.Backend)
+	}
+	if cfg.Output.Toggled() && name != "" && name != string(backend.VLLM) {
+		v.add("%soutput.forcedRatio requires vllm backend, %s can not toggle ignore_eos per request", prefix, cfg.Backend)
+	}
+	for _, field := range infer.UnsupportedSampling(name, &cfg.Sampling) {
+		v.add("%ssampling.%s is not supported by backend %s", prefix, field, cfg.Backend)
+	}
+	if name == string(backend.TGI) && cfg.Sampling.BestOf > 1 && cfg.Temperature == 0 {
+		// TGI 温度为 0 时关闭采样，而 best_of 要求开启采样
+		v.add("%ssampling.bestOf > 1 requires temperature > 0 for backend tgi", prefix)
+	}
+}
+
+// workload 校验混合负载中每类请求的权重和长度分布
+func (v *validator) workload(cfg *config.Config) {
+	names := make(map[string]bool)
+	source := cfg.Prompt.GetSource()
+	for i, c := range cfg.Workload.Classes {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		} else if names[name] {
+			v.add("workload class name %s is duplicated", name)
+		}
+		names[name] = true
+		if c.Weight <= 0 {
+			v.add("workload class %s weight must be > 0, got %v", name, c.Weight)
+		}
+		if err := workload.Validate(c.Input); err != nil {
+			v.add("workload class %s input: %v", name, err)
+		}
+		if err := workload.Validate(c.Output); err != nil {
+			v.add("workload class %s output: %v", name, err)
+		}
+		if c.Input.Type != "" && source != config.PromptSourceBuckets && source != config.PromptSourceSynthetic {
+			v.add("workload class %s input distribution requires %s or %s prompt source, got %s",
+				name, config.PromptSourceBuckets, config.PromptSourceSynthetic, source)
+		}
+	}
+}
+
+// endpoints 校验多个副本地址和负载均衡策略
+func (v *validator) endpoints(cfg *config.Config, prefix string) {
+	for i, e := range cfg.Endpoints {
+		if !strings.HasPrefix(e.Url, "http://") && !strings.HasPrefix(e.Url, "https://") {
+			v.add("%sendpoints[%d].url must start with http:// or https://, got %q", prefix, i, e.Url)
+		}
+		if e.Weight < 0 {
+			v.add("%sendpoints[%d].weight must be >= 0, got %d", prefix, i, e.Weight)
+		}
+	}
+	switch cfg.GetLoadBalance() {
+	case config.BalanceRoundRobin, config.BalanceLeastInflight, config.BalanceRandom:
+	default:
+		v.add("%sloadBalance must be one of %s, %s, %s, got %q", prefix,
+			config.BalanceRoundRobin, config.BalanceLeastInflight, config.BalanceRandom, cfg.LoadBalance)
+	}
+}
+
+// modelMix 校验模型混合中每个模型的名称和权重
+func (v *validator) modelMix(cfg *config.Config) {
+	names := make(map[string]bool)
+	for i, m := range cfg.Model.Mix {
+		if m.Name == "" {
+			v.add("model.mix[%d].name is required", i)
+		} else if names[m.Name] {
+			v.add("model.mix name %s is duplicated", m.Name)
+		}
+		names[m.Name] = true
+		if m.Weight <= 0 {
+			v.add("model.mix[%d].weight must be > 0, got %v", i, m.Weight)
+		}
+	}
+}
+
+// ab 校验 A/B 对比测试的对比服务，后端相关的配置在 run 中校验
+func (v *validator) ab(cfg *config.Config) {
+	if !cfg.AB.Enabled() {
+		return
+	}
+	t := &cfg.AB.Candidate
+	if t.GetName() == cfg.AB.GetName() {
+		v.add("ab.name and ab.candidate.name must be different, got %q", t.GetName())
+	}
+	cand := cfg.Candidate()
+	if len(cand.Endpoints) == 0 && cand.Domain == "" && (cand.ServerIp == "" || cand.Port <= 0) {
+		v.add("ab.candidate requires either endpoints, domain or serverIp and port")
+	}
+	if len(t.Endpoints) > 0 {
+		v.endpoints(cand, "ab.candidate.")
+	}
+	if len(t.Model.Mix) > 0 {
+		v.add("ab.candidate.model.mix is not supported, the candidate uses the baseline model mix when its model is not set")
+	}
+	if cfg.Session.Enabled() {
+		v.add("ab and session can not be used together, conversations diverge between the two targets")
+	}
+}
+
+// session 校验多轮对话负载的配置
+func (v *validator) session(cfg *config.Config) {
+	s := &cfg.Session
+	if s.Turns < 0 {
+		v.add("session.turns must be >= 0, got %d", s.Turns)
+	}
+	if s.ThinkTime < 0 {
+		v.add("session.thinkTime must be >= 0 ms, got %d", s.ThinkTime)
+	}
+	if !s.Enabled() {
+		return
+	}
+	if !cfg.Workload.Empty() {
+		v.add("session and workload can not be used together")
+	}
+	if s.Path != "" {
+		v.dataset(s.Path, "")
+	}
+}
+
+// sharedPrefix 校验共享前缀负载的配置
+func (v *validator) sharedPrefix(cfg *config.Config) {
+	p := &cfg.SharedPrefix
+	if p.Tokens < 0 {
+		v.add("sharedPrefix.tokens must be >= 0, got %d", p.Tokens)
+	}
+	if p.Control && !p.Enabled() {
+		v.add("sharedPrefix.control requires sharedPrefix.tokens > 0")
+	}
+	if p.Enabled() && cfg.Session.Enabled() {
+		v.add("sharedPrefix and session can not be used together")
+	}
+}
+
+// output 校验目标输出长度的配置
+func (v *validator) output(cfg *config.Config) {
+	o := &cfg.Output
+	if err := workload.Validate(o.Length); err != nil {
+		v.add("output.length: %v", err)
+	}
+	if o.Toggled() && (*o.ForcedRatio < 0 || *o.ForcedRatio > 1) {
+		v.add("output.forcedRatio must be in [0, 1], got %v", *o.ForcedRatio)
+	}
+	source := cfg.Prompt.GetSource()
+	if o.Reference && source != config.PromptSourceBuckets && source != config.PromptSourceFile {
+		v.add("output.reference requires %s or %s prompt source with output_tokens, got %s",
+			config.PromptSourceBuckets, config.PromptSourceFile, source)
+	}
+}
+
+// sampling 校验采样参数的取值范围
+func (v *validator) sampling(cfg *config.Config) {
+	s := &cfg.Sampling
+	if s.Temperature != nil && *s.Temperature < 0 {
+		v.add("sampling.temperature must be >= 0, got %v", *s.Temperature)
+	}
+	if s.TopP != nil && (*s.TopP <= 0 || *s.TopP > 1) {
+		v.add("sampling.topP must be in (0, 1], got %v", *s.TopP)
+	}
+	if s.TopK != nil && *s.TopK == 0 {
+		v.add("sampling.topK must not be 0")
+	}
+	if s.RepetitionPenalty != nil && *s.RepetitionPenalty <= 0 {
+		v.add("sampling.repetitionPenalty must be > 0, got %v", *s.RepetitionPenalty)
+	}
+	if s.N < 0 || s.BestOf < 0 || s.BeamWidth < 0 || s.MinTokens < 0 {
+		v.add("sampling.n, bestOf, beamWidth and minTokens must be >= 0")
+	}
+	if s.BestOf > 0 && s.BestOf < s.GetN() {
+		v.add("sampling.bestOf %d must be >= n %d", s.BestOf, s.GetN())
+	}
+	if s.BeamWidth > 1 && s.BeamWidth < s.GetN() {
+		v.add("sampling.beamWidth %d must be >= n %d", s.BeamWidth, s.GetN())
+	}
+}
+
+// prompt 校验 prompt 来源，数据集文件需要存在
+func (v *validator) prompt(cfg *config.Config, prefix string) {
+	source := cfg.Prompt.GetSource()
+	switch source {
+	case config.PromptSourceBuckets:
+		path := cfg.Prompt.BucketPath(cfg.InputTokens)
+		if _, err := os.Stat(path); err != nil {
+			v.add("%sno dataset for inputTokens %d, use synthetic prompt source for other lengths: %v",
+				prefix, cfg.InputTokens, err)
+			return
+		}
+		v.dataset(path, prefix)
+	case config.PromptSourceFile, config.PromptSourceChat:
+		if cfg.Prompt.Path == "" {
+			v.add("prompt.path is required for prompt source %s", source)
+			return
+		}
+		v.dataset(cfg.Prompt.Path, prefix)
+	case config.PromptSourceSynthetic:
+		if cfg.InputTokens <= 0 {
+			v.add("%sinputTokens must be > 0 for synthetic prompts, got %d", prefix, cfg.InputTokens)
+		}
+	default:
+		v.add("prompt.source must be one of %s, %s, %s, %s, got %q", config.PromptSourceBuckets,
+			config.PromptSourceFile, config.PromptSourceChat, config.PromptSourceSynthetic, source)
+	}
+	if path := cfg.Prompt.Tokenizer; path != "" {
+		if _, err := os.Stat(path); err != nil {
+			v.add("can not open prompt.tokenizer: %v", err)
+		}
+	}
+}
+
+// dataset 校验数据集文件是否可以读取，并且不是 git lfs 的指针文件
+func (v *validator) dataset(path, prefix string) {
+	file, err := os.Open(path)
+	if err != nil {
+		v.add("%scan not open dataset: %v", prefix, err)
+		return
+	}
+	defer file.Close()
+	head := make([]byte, len(lfsPointerPrefix))
+	n, _ := file.Read(head)
+	if bytes.Equal(head[:n], []byte(lfsPointerPrefix)) {
+		v.add("%sdataset %s is a git lfs pointer, run git lfs pull first", prefix, path)
+	}
+}
+
+func requireEnv(v *validator, field string, names ...string) {
+	for _, name := range names {
+		if os.Getenv(name) == "" {
+			v.add("%s requires environment variable %s", field, name)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package workload
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nullxjx/llm_profiler/config"
+)
+
+// sampler 长度分布的采样器
+type sampler interface {
+	sample(r *rand.Rand) int
+	// bounds 返回可能采样到的最小值和最大值，max 为 0 表示没有上界
+	bounds() (int, int)
+}
+
+// newSampler 根据配置创建采样器，type 为空时返回 nil
+func newSampler(d config.Distribution) (sampler, error) {
+	switch d.Type {
+	case "":
+		return nil, nil
+	case config.DistFixed:
+		if d.Value <= 0 {
+			return nil, fmt.Errorf("fixed value must be > 0, got %d", d.Value)
+		}
+		return fixed(d.Value), nil
+	case config.DistUniform:
+		if d.Min <= 0 || d.Max < d.Min {
+			return nil, fmt.Errorf("uniform range must satisfy 0 < min <= max, got [%d, %d]", d.Min, d.Max)
+		}
+		return uniform{min: d.Min, max: d.Max}, nil
+	case config.DistLognormal:
+		if d.Median <= 0 || d.Sigma < 0 {
+			return nil, fmt.Errorf("lognormal requires median > 0 and sigma >= 0, got median %v, sigma %v",
+				d.Median, d.Sigma)
+		}
+		if d.Min < 0 || d.Max != 0 && d.Max < d.Min {
+			return nil, fmt.Errorf("lognormal bounds must satisfy 0 <= min <= max, got [%d, %d]", d.Min, d.Max)
+		}
+		return lognormal{median: d.Median, sigma: d.Sigma, min: d.Min, max: d.Max}, nil
+	case config.DistHistogram:
+		return readHistogram(d.File)
+	default:
+		return nil, fmt.Errorf("unsupported distribution type %q, supported: %s, %s, %s, %s", d.Type,
+			config.DistFixed, config.DistUniform, config.DistLognormal, config.DistHistogram)
+	}
+}
+
+// Validate 校验分布配置
+func Validate(d config.Distribution) error {
+	_, err := newSampler(d)
+	return err
+}
+
+type fixed int
+
+func (f fixed) sample(*rand.Rand) int {
+	return int(f)
+}
+
+func (f fixed) bounds() (int, int) {
+	return int(f), int(f)
+}
+
+type uniform struct {
+	min, max int
+}
+
+func (u uniform) sample(r *rand.Rand) int {
+	return u.min + r.Intn(u.max-u.min+1)
+}
+
+func (u uniform) bounds() (int, int) {
+	return u.min, u.max
+}
+
+type lognormal struct {
+	median, sigma float64
+	min, max      int
+}
+
+func (l lognormal) sample(r *rand.Rand) int {
+	v := int(math.Round(l.median * math.Exp(l.sigma*r.NormFloat64())))
+	if v < l.min {
+		v = l.min
+	}
+	if l.max > 0 && v > l.max {
+		v = l.max
+	}
+	return max(v, 1)
+}
+
+func (l lognormal) bounds() (int, int) {
+	return max(l.min, 1), l.max
+}
+
+// span 返回实际会采样到的长度范围，lognormal 的两端各取 4 倍对数标准差处的值（超出的概率各约 3e-5），再按 min 和 max 截断
+func span(s sampler) (int, int) {
+	lo, hi := s.bounds()
+	if l, ok := s.(lognormal); ok {
+		lo = max(lo, int(math.Floor(l.median*math.Exp(-4*l.sigma))))
+		if up := max(int(math.Ceil(l.median*math.Exp(4*l.sigma))), lo); hi == 0 || up < hi {
+			hi = up
+		}
+	}
+	return lo, hi
+}
+
+// histogram 经验分布，按权重选择长度
+type histogram struct {
+	values     []int
+	cumulative []float64
+}
+
+func (h *histogram) sample(r *rand.Rand) int {
+	x := r.Float64() * h.cumulative[len(h.cumulative)-1]
+	return h.values[sort.SearchFloat64s(h.cumulative, x)]
+}
+
+func (h *histogram) bounds() (int, int) {
+	lo, hi := h.values[0], h.values[0]
+	for _, v := range h.values {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	return lo, hi
+}
+
+// readHistogram 读取经验分布文件，每行为长度和权重，用空格或逗号分隔，# 开头的行为注释
+func readHistogram(path string) (*histogram, error) {
+	if path == "" {
+		return nil, fmt.Errorf("histogram file is required")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	h := &histogram{}
+	total := 0.
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s line %d: expected \"length weight\", got %q", path, line, text)
+		}
+		value, err := strconv.Atoi(fields[0])
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("%s line %d: invalid length %q", path, line, fields[0])
+		}
+		weight, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("%s line %d: invalid weight %q", path, line, fields[1])
+		}
+		total += weight
+		h.values = append(h.values, value)
+		h.cumulative = append(h.cumulative, total)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("%s: no positive weight", path)
+	}
+	return h, nil
+}
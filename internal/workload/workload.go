@@ -0,0 +1,333 @@
+package workload
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+
+	"github.com/nullxjx/llm_profiler/config"
+	"github.com/nullxjx/llm_profiler/internal/prompt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// syntheticPoolSize 随机生成 prompt 时每个长度生成的数量
+const syntheticPoolSize = 32
+
+// syntheticGridSize 随机生成 prompt 时每类请求最多使用的输入长度数，采样到的长度取最接近的一个，
+// 所有长度的 prompt 在测试开始前生成，避免发送请求时生成 prompt
+const syntheticGridSize = 32
+
+// modelSeedOffset 选择模型的随机数种子相对采样种子的偏移，配置模型混合不会改变其他采样结果
+const modelSeedOffset = 1 << 40
+
+// Request 一条待发送请求的输入
+type Request struct {
+	Class       string         // 所属类别，没有设置混合负载时为空
+	Prompt      *prompt.Prompt // 输入
+	InputTokens int            // 采样的输入token数，0 表示未采样
+	MaxTokens   uint32         // 采样的最大输出token数，0 表示使用配置中的 maxTokens
+	StopAtEos   bool           // 遇到 eos 时停止生成
+	EosMode     string         // 按比例切换是否忽略 eos 时为 config.EosForced 或 config.EosNatural
+	Model       string         // 按权重选择的模型，没有设置模型混合时为空
+}
+
+type class struct {
+	name   string
+	input  sampler
+	output sampler
+}
+
+// Workload 按配置生成每条请求的输入，没有设置混合负载时依次使用 prompts 中的数据
+type Workload struct {
+	cfg        *config.Config
+	prompts    []*prompt.Prompt
+	classes    []*class
+	cumulative []float64
+	buckets    []int                    // 数据集中所有分组，随机生成 prompt 时为预先确定的输入长度
+	pools      map[int][]*prompt.Prompt // 每个分组的 prompt
+	next       map[int]int              // 每个分组下一条使用的 prompt
+	rand       *rand.Rand
+	output     sampler // 没有按类别采样输出长度时使用的目标输出长度分布
+
+	gen        *prompt.Generator
+	prefix     string     // 共享前缀，没有设置时为空
+	control    bool       // 是否为对照轮次，每条请求使用不同的前缀
+	prefixRand *rand.Rand // 生成对照轮次的前缀，与采样使用不同的随机数，保证两轮的请求相同
+
+	models          []string   // 模型混合中的模型名称
+	modelCumulative []float64  // 模型混合的累计权重
+	modelRand       *rand.Rand // 选择模型的随机数
+}
+
+// New 创建负载，prompts 为按配置读取的 prompt，会预先读取混合负载可能用到的数据集分组
+func New(cfg *config.Config, prompts []*prompt.Prompt) (*Workload, error) {
+	w := &Workload{
+		cfg:     cfg,
+		prompts: prompts,
+		pools:   make(map[int][]*prompt.Prompt),
+		next:    make(map[int]int),
+	}
+	total := 0.
+	for i, c := range cfg.Workload.Classes {
+		input, err := newSampler(c.Input)
+		if err != nil {
+			return nil, fmt.Errorf("workload class %s input: %v", c.Name, err)
+		}
+		output, err := newSampler(c.Output)
+		if err != nil {
+			return nil, fmt.Errorf("workload class %s output: %v", c.Name, err)
+		}
+		if c.Weight <= 0 {
+			return nil, fmt.Errorf("workload class %s weight must be > 0", c.Name)
+		}
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("class_%d", i)
+		}
+		total += c.Weight
+		w.classes = append(w.classes, &class{name: name, input: input, output: output})
+		w.cumulative = append(w.cumulative, total)
+	}
+	output, err := newSampler(cfg.Output.Length)
+	if err != nil {
+		return nil, fmt.Errorf("output length: %v", err)
+	}
+	w.output = output
+	total = 0
+	for _, m := range cfg.Model.Mix {
+		if m.Weight <= 0 {
+			return nil, fmt.Errorf("model mix %s weight must be > 0", m.Name)
+		}
+		total += m.Weight
+		w.models = append(w.models, m.Name)
+		w.modelCumulative = append(w.modelCumulative, total)
+	}
+	if err := w.preload(); err != nil {
+		return nil, err
+	}
+	if n := cfg.SharedPrefix.Tokens; n > 0 {
+		gen, err := prompt.NewGenerator(cfg)
+		if err != nil {
+			return nil, err
+		}
+		prefix, err := gen.Generate(rand.New(rand.NewSource(cfg.Workload.Seed)), n)
+		if err != nil {
+			return nil, err
+		}
+		w.gen, w.prefix = gen, prefix+"\n"
+	}
+	w.Reset(0)
+	return w, nil
+}
+
+// Reset 在每轮开始时重置随机数，相同的种子和并发度采样结果相同
+func (w *Workload) Reset(concurrency int) {
+	w.rand = rand.New(rand.NewSource(w.cfg.Workload.Seed + int64(concurrency)))
+	w.prefixRand = rand.New(rand.NewSource(w.cfg.Workload.Seed - int64(concurrency) - 1))
+	w.modelRand = rand.New(rand.NewSource(w.cfg.Workload.Seed + int64(concurrency) + modelSeedOffset))
+	for k := range w.next {
+		w.next[k] = 0
+	}
+}
+
+// SetControl 设置是否为共享前缀的对照轮次，对照轮次中每条请求的前缀长度相同但内容各不相同
+func (w *Workload) SetControl(control bool) {
+	w.control = control
+}
+
+// Control 是否为共享前缀的对照轮次
+func (w *Workload) Control() bool {
+	return w.control
+}
+
+// Next 生成第 i 条请求的输入，只能在一个协程中调用
+func (w *Workload) Next(i int) (*Request, error) {
+	req, err := w.sample(i)
+	if err != nil {
+		return nil, err
+	}
+	w.target(req)
+	if len(w.models) > 0 {
+		x := w.modelRand.Float64() * w.modelCumulative[len(w.modelCumulative)-1]
+		req.Model = w.models[sort.SearchFloat64s(w.modelCumulative, x)]
+	}
+	if w.prefix == "" {
+		return req, nil
+	}
+	prefix := w.prefix
+	if w.control {
+		if prefix, err = w.gen.Generate(w.prefixRand, w.cfg.SharedPrefix.Tokens); err != nil {
+			return nil, err
+		}
+		prefix += "\n"
+	}
+	req.Prompt = prompt.WithPrefix(req.Prompt, prefix)
+	return req, nil
+}
+
+// target 设置没有按类别采样输出长度的请求的目标长度，并按比例决定是否忽略 eos
+func (w *Workload) target(req *Request) {
+	o := &w.cfg.Output
+	if req.MaxTokens == 0 {
+		if o.Reference && req.Prompt.OutputTokens > 0 {
+			req.MaxTokens = uint32(req.Prompt.OutputTokens)
+		} else if w.output != nil {
+			req.MaxTokens = uint32(w.output.sample(w.rand))
+		}
+	}
+	if o.Toggled() {
+		req.StopAtEos = w.rand.Float64() >= *o.ForcedRatio
+		req.EosMode = config.EosForced
+		if req.StopAtEos {
+			req.EosMode = config.EosNatural
+		}
+	}
+}
+
+// sample 按混合负载的配置选择第 i 条请求的 prompt 和长度
+func (w *Workload) sample(i int) (*Request, error) {
+	if len(w.classes) == 0 {
+		return &Request{Prompt: w.prompts[i%len(w.prompts)]}, nil
+	}
+	x := w.rand.Float64() * w.cumulative[len(w.cumulative)-1]
+	c := w.classes[sort.SearchFloat64s(w.cumulative, x)]
+	req := &Request{Class: c.name}
+	if c.input != nil {
+		req.InputTokens = w.snap(c.input.sample(w.rand))
+		pool, err := w.pool(req.InputTokens)
+		if err != nil {
+			return nil, err
+		}
+		req.Prompt = pool[w.next[req.InputTokens]%len(pool)]
+		w.next[req.InputTokens]++
+	} else {
+		req.Prompt = w.prompts[i%len(w.prompts)]
+	}
+	if c.output != nil {
+		req.MaxTokens = uint32(c.output.sample(w.rand))
+	}
+	return req, nil
+}
+
+// preload 检查 prompt 来源是否支持输入长度分布，并读取可能用到的数据集分组或生成随机 prompt
+func (w *Workload) preload() error {
+	source := w.cfg.Prompt.GetSource()
+	var inputs []sampler
+	for _, c := range w.classes {
+		if c.input != nil {
+			inputs = append(inputs, c.input)
+		}
+	}
+	if len(inputs) == 0 {
+		return nil
+	}
+	switch source {
+	case config.PromptSourceSynthetic:
+		w.buckets = syntheticGrid(inputs)
+		log.Infof("Generate synthetic prompts for %d input lengths from %d to %d",
+			len(w.buckets), w.buckets[0], w.buckets[len(w.buckets)-1])
+	case config.PromptSourceBuckets:
+		if err := w.readBuckets(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("workload input distribution requires %s or %s prompt source, got %s",
+			config.PromptSourceBuckets, config.PromptSourceSynthetic, source)
+	}
+
+	for _, s := range inputs {
+		lo, hi := s.bounds()
+		if hi == 0 { // 没有上界时可能采样到任意分组
+			hi = w.buckets[len(w.buckets)-1]
+		}
+		lo, hi = w.snap(lo), w.snap(hi)
+		for _, b := range w.buckets {
+			if b < lo || b > hi {
+				continue
+			}
+			if _, err := w.pool(b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readBuckets 读取数据集目录中的所有分组
+func (w *Workload) readBuckets() error {
+	paths, err := filepath.Glob(filepath.Join(w.cfg.Prompt.BucketDir(), "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		var n int
+		if _, err := fmt.Sscanf(filepath.Base(path), config.DatasetFileFormat, &n); err == nil && n > 0 {
+			w.buckets = append(w.buckets, n)
+		}
+	}
+	if len(w.buckets) == 0 {
+		return fmt.Errorf("no dataset buckets found in %s", w.cfg.Prompt.BucketDir())
+	}
+	sort.Ints(w.buckets)
+	return nil
+}
+
+// syntheticGrid 随机生成 prompt 时使用的输入长度，每类请求在可能采样到的范围内按等比取至多 syntheticGridSize 个长度，
+// 相邻长度的差距不超过范围的等比步长，保证 prompt 数量有上界
+func syntheticGrid(inputs []sampler) []int {
+	seen := make(map[int]bool)
+	var grid []int
+	add := func(n int) {
+		if !seen[n] {
+			seen[n] = true
+			grid = append(grid, n)
+		}
+	}
+	for _, s := range inputs {
+		lo, hi := span(s)
+		lo = max(lo, 1)
+		ratio := math.Pow(float64(hi)/float64(lo), 1/float64(syntheticGridSize-1))
+		for i := 0; i < syntheticGridSize-1; i++ {
+			add(int(math.Round(float64(lo) * math.Pow(ratio, float64(i)))))
+		}
+		add(hi)
+	}
+	sort.Ints(grid)
+	return grid
+}
+
+// snap 返回与 n 最接近的数据集分组或随机生成 prompt 的输入长度
+func (w *Workload) snap(n int) int {
+	if len(w.buckets) == 0 {
+		return n
+	}
+	i := sort.SearchInts(w.buckets, n)
+	if i == len(w.buckets) {
+		return w.buckets[i-1]
+	}
+	if i > 0 && n-w.buckets[i-1] < w.buckets[i]-n {
+		return w.buckets[i-1]
+	}
+	return w.buckets[i]
+}
+
+// pool 返回给定输入token数的 prompt，没有读取过时读取，随机生成时只会用到 preload 中确定的长度
+func (w *Workload) pool(inputTokens int) ([]*prompt.Prompt, error) {
+	if pool, ok := w.pools[inputTokens]; ok {
+		return pool, nil
+	}
+	c := *w.cfg
+	c.InputTokens = inputTokens
+	if c.Prompt.GetSource() == config.PromptSourceSynthetic {
+		c.Prompt.Count = min(c.Prompt.GetCount(), syntheticPoolSize)
+	}
+	pool, err := prompt.Load(&c)
+	if err != nil {
+		return nil, fmt.Errorf("load prompts with %d input tokens error: %v", inputTokens, err)
+	}
+	w.pools[inputTokens] = pool
+	return pool, nil
+}
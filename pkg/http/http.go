@@ -45,6 +45,28 @@ func Post(ctx context.Context, url string, rawBody interface{}) ([]byte, error)
 	return respBody, nil
 }
 
+// Get 发起 GET 请求，状态码不为 200 时返回错误
+func Get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, errors.New(fmt.Sprintf("status code: %d, body: %v", resp.StatusCode, string(respBody)))
+	}
+	return respBody, nil
+}
+
 // Stream 发起流式请求，需调用unwrapStreamError来获取流式过程中的报错信息
 func Stream(ctx context.Context, url string, header, queryParam map[string]string, body interface{}) (
 	<-chan []byte, error) {
@@ -67,6 +89,12 @@ func Stream(ctx context.Context, url string, header, queryParam map[string]strin
 		log.Errorf("HttpClient Stream error: %v", jsonErr)
 		return nil, jsonErr
 	}
+	if resp.StatusCode() != http.StatusOK {
+		// 出错时服务返回的不是流式数据，不能当作输出解析
+		defer resp.RawBody().Close()
+		respBody, _ := io.ReadAll(resp.RawBody())
+		return nil, errors.New(fmt.Sprintf("stream error, status code: %d, body: %v", resp.StatusCode(), string(respBody)))
+	}
 	out := make(chan []byte, 4096)
 	reader := bufio.NewReader(resp.RawBody())
 	go func() {
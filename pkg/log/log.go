@@ -42,7 +42,40 @@ func getColorByLevel(level log.Level) string {
 	}
 }
 
-func SetLogFile(logFile string) error {
+// SetLogFile 日志同时输出到文件和控制台，返回的函数用于恢复之前的输出并关闭文件
+func SetLogFile(logFile string) (func(), error) {
+	file, err := openLogFile(logFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// 设置 logrus 输出到文件和控制台
+	return setOutput(io.MultiWriter(os.Stdout, file), file), nil
+}
+
+// SetLogFileOnly 日志只输出到文件，用于终端被其他内容（如实时面板）占用的场景
+func SetLogFileOnly(logFile string) (func(), error) {
+	file, err := openLogFile(logFile)
+	if err != nil {
+		return nil, err
+	}
+	return setOutput(file, file), nil
+}
+
+// setOutput 设置 logrus 的输出，返回的函数恢复之前的输出并关闭 file，
+// 服务模式下每个任务结束后都需要调用，避免日志写入上一个任务的文件以及文件句柄泄漏
+func setOutput(out io.Writer, file *os.File) func() {
+	previous := log.StandardLogger().Out
+	log.SetOutput(out)
+	return func() {
+		log.SetOutput(previous)
+		if err := file.Close(); err != nil {
+			log.Errorf("close log file error: %v", err)
+		}
+	}
+}
+
+func openLogFile(logFile string) (*os.File, error) {
 	// 获取文件所在的目录
 	dir := filepath.Dir(logFile)
 
@@ -50,7 +83,7 @@ func SetLogFile(logFile string) error {
 	err := os.MkdirAll(dir, os.ModePerm)
 	if err != nil {
 		log.Errorf("failed to create directories: %v", err)
-		return err
+		return nil, err
 	}
 
 	// 创建日志文件
@@ -58,9 +91,5 @@ func SetLogFile(logFile string) error {
 	if err != nil {
 		log.Fatalf("Failed to open log file: %v", err)
 	}
-
-	// 设置 logrus 输出到文件和控制台
-	log.SetOutput(io.MultiWriter(os.Stdout, file))
-
-	return nil
+	return file, nil
 }
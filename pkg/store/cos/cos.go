@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/nullxjx/llm_profiler/config"
@@ -31,18 +32,7 @@ const (
 // SaveFilesToCos 把 saveDir 目录中的文件保存到腾讯云cos
 func SaveFilesToCos(cfg *config.Config) (string, string, error) {
 	saveDir := cfg.SaveDir
-	// 创建 COS 客户端
-	u, _ := url.Parse(fmt.Sprintf("http://%s.cos.%s.myqcloud.com",
-		os.Getenv(EnvBucket), os.Getenv(EnvRegion)))
-	client := cos.NewClient(&cos.BaseURL{BucketURL: u}, &http.Client{
-		Transport: &cos.AuthorizationTransport{
-			SecretKey: os.Getenv(EnvSecretKey),
-			SecretID:  os.Getenv(EnvSecretID),
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-			},
-		},
-	})
+	client := newClient()
 
 	downloadUrl := ""
 	dstDir := fmt.Sprintf("%s/%s", os.Getenv(EnvSubFolder), cfg.SaveDir)
@@ -76,6 +66,21 @@ func SaveFilesToCos(cfg *config.Config) (string, string, error) {
 	}
 }
 
+// newClient 使用环境变量中的配置创建 COS 客户端
+func newClient() *cos.Client {
+	u, _ := url.Parse(fmt.Sprintf("http://%s.cos.%s.myqcloud.com",
+		os.Getenv(EnvBucket), os.Getenv(EnvRegion)))
+	return cos.NewClient(&cos.BaseURL{BucketURL: u}, &http.Client{
+		Transport: &cos.AuthorizationTransport{
+			SecretKey: os.Getenv(EnvSecretKey),
+			SecretID:  os.Getenv(EnvSecretID),
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		},
+	})
+}
+
 func uploadFileToCOS(client *cos.Client, filePath, srcDir, dstDir string) error {
 	// 读取文件内容
 	fileContent, err := ioutil.ReadFile(filePath)
@@ -144,3 +149,48 @@ func DownloadCosFile(secretID, secretKey, region, bucket, cosPath, localPath str
 	log.Infof("⬇⬇⬇ download cos file %s to local path: %s", cosPath, localPath)
 	return nil
 }
+
+// DownloadCosDir 把 cos 上 cosDir 目录下的所有文件下载到本地 localDir 目录，cos 配置从环境变量中读取
+func DownloadCosDir(cosDir, localDir string) error {
+	client := newClient()
+	prefix := strings.TrimSuffix(cosDir, "/") + "/"
+	marker := ""
+	count := 0
+	for {
+		res, _, err := client.Bucket.Get(context.Background(), &cos.BucketGetOptions{
+			Prefix: prefix,
+			Marker: marker,
+		})
+		if err != nil {
+			return fmt.Errorf("list cos dir %s error: %v", cosDir, err)
+		}
+		for _, object := range res.Contents {
+			localPath := filepath.Join(localDir, strings.TrimPrefix(object.Key, prefix))
+			if err = os.MkdirAll(filepath.Dir(localPath), os.ModePerm); err != nil {
+				return err
+			}
+			response, err := client.Object.Get(context.Background(), object.Key, nil)
+			if err != nil {
+				return fmt.Errorf("download cos file %s error: %v", object.Key, err)
+			}
+			content, err := ioutil.ReadAll(response.Body)
+			response.Body.Close()
+			if err != nil {
+				return err
+			}
+			if err = ioutil.WriteFile(localPath, content, 0644); err != nil {
+				return err
+			}
+			count++
+		}
+		if !res.IsTruncated {
+			break
+		}
+		marker = res.NextMarker
+	}
+	if count == 0 {
+		return fmt.Errorf("no file found in cos dir %s", cosDir)
+	}
+	log.Infof("⬇⬇⬇ download %d files from cos dir %s to local path: %s", count, cosDir, localDir)
+	return nil
+}